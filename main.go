@@ -1,37 +1,49 @@
 package main
 
 import (
-	"compress/gzip"
+	"bufio"
 	"context"
+	"crypto/tls"
 	"fmt"
-	"io"
-	"log"
 	"net"
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/klog/v2"
 
+	"k8s.io/apimachinery/pkg/labels"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/tools/clientcmd"
 
+	"github.com/openshift/openshift-state-metrics/pkg/admin"
+	"github.com/openshift/openshift-state-metrics/pkg/auth"
 	"github.com/openshift/openshift-state-metrics/pkg/proc"
+	"github.com/openshift/openshift-state-metrics/pkg/tlsconfig"
 	"github.com/openshift/openshift-state-metrics/pkg/version"
-	kcollectors "k8s.io/kube-state-metrics/pkg/collector"
 	koptions "k8s.io/kube-state-metrics/pkg/options"
 	"k8s.io/kube-state-metrics/pkg/whiteblacklist"
 
 	ocollectors "github.com/openshift/openshift-state-metrics/pkg/collectors"
+	"github.com/openshift/openshift-state-metrics/pkg/metricshandler"
 	"github.com/openshift/openshift-state-metrics/pkg/options"
 )
 
 const (
-	metricsPath = "/metrics"
-	healthzPath = "/healthz"
+	metricsPath       = "/metrics"
+	tenantMetricsPath = "/metrics/tenant"
+	healthzPath       = "/healthz"
+	readyzPath        = "/readyz"
+	livezPath         = "/livez"
 )
 
 // promLogger implements promhttp.Logger
@@ -42,6 +54,13 @@ func (pl promLogger) Println(v ...interface{}) {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		if err := render(); err != nil {
+			klog.Fatalf("Error: %s", err)
+		}
+		return
+	}
+
 	opts := options.NewOptions()
 	opts.AddFlags()
 
@@ -49,6 +68,11 @@ func main() {
 	if err != nil {
 		klog.Fatalf("Error: %s", err)
 	}
+	if opts.Config != "" {
+		if err := opts.LoadConfig(opts.Config); err != nil {
+			klog.Fatalf("Failed to load config file: %v", err)
+		}
+	}
 
 	if opts.Version {
 		fmt.Printf("%#v\n", version.GetVersion())
@@ -60,54 +84,420 @@ func main() {
 		os.Exit(0)
 	}
 
-	collectorBuilder := ocollectors.NewBuilder(context.TODO())
-	collectorBuilder.WithApiserver(opts.Apiserver).WithKubeConfig(opts.Kubeconfig)
+	restConfig, err := clientcmd.BuildConfigFromFlags(opts.Apiserver, opts.Kubeconfig)
+	if err != nil {
+		klog.Fatalf("Failed to create client config: %v", err)
+	}
+
+	if opts.AutoSharding && (opts.Shard != 0 || opts.TotalShards != 1) {
+		klog.Fatal("--auto-sharding cannot be combined with --shard and --total-shards")
+	}
+	if opts.TotalShards < 1 || opts.Shard < 0 || opts.Shard >= int32(opts.TotalShards) {
+		klog.Fatalf("--shard must be between 0 and %d", opts.TotalShards-1)
+	}
+	if opts.EnableAdminAPI && !opts.AuthDelegation {
+		// Everyone reaching the telemetry port could disable collectors.
+		klog.Fatal("--enable-admin-api requires --auth-delegation")
+	}
+	if opts.ListPageSize < 0 {
+		klog.Fatal("--list-page-size must not be negative")
+	}
+
+	clients, err := ocollectors.NewClientFactory(restConfig)
+	if err != nil {
+		klog.Fatalf("Failed to create clients: %v", err)
+	}
+
+	settings, err := newCollectorSettings(opts)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	var customResourceState *ocollectors.CustomResourceStateConfig
+	if opts.CustomResourceStateConfigFile != "" {
+		customResourceState, err = ocollectors.LoadCustomResourceStateConfig(opts.CustomResourceStateConfigFile)
+		if err != nil {
+			klog.Fatalf("Failed to load custom resource state config: %v", err)
+		}
+	}
+
+	compression := metricshandler.Compression{
+		Encodings: opts.Compression,
+		GzipLevel: opts.CompressionGzipLevel,
+		ZstdLevel: opts.CompressionZstdLevel,
+	}
+	if opts.EnableGZIPEncoding && !slices.Contains(compression.Encodings, "gzip") {
+		compression.Encodings = append(compression.Encodings, "gzip")
+	}
+	if err := compression.Validate(); err != nil {
+		klog.Fatalf("Invalid compression: %v", err)
+	}
+
+	// ctx ends the watches and reflectors once the servers are shut down.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// signals is done once the process is asked to terminate.
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	var tlsConfig *tls.Config
+	serverTLS := tlsconfig.Config{
+		CertFile:     opts.TLSCertFile,
+		KeyFile:      opts.TLSPrivateKeyFile,
+		ClientCAFile: opts.ClientCAFile,
+		MinVersion:   opts.TLSMinVersion,
+		CipherSuites: opts.TLSCipherSuites,
+		// Clients authenticating with a bearer token need no certificate.
+		OptionalClientCert: opts.AuthDelegation,
+	}
+	if err := serverTLS.Validate(); err != nil {
+		klog.Fatalf("Invalid TLS configuration: %v", err)
+	}
+	if serverTLS.Enabled() {
+		tlsConfig, err = tlsconfig.NewServerConfig(ctx, serverTLS, tlsconfig.DefaultReloadInterval)
+		if err != nil {
+			klog.Fatalf("Failed to load TLS certificates: %v", err)
+		}
+	}
+
+	// protect wraps the handlers which need authorization.
+	protect := func(h http.Handler) http.Handler { return h }
+	var tenantHandler *metricshandler.TenantHandler
+	if opts.AuthDelegation {
+		resourceAttributes, err := auth.ParseResourceAttributes(opts.AuthResourceAttributes)
+		if err != nil {
+			klog.Fatalf("Invalid --auth-resource-attributes: %v", err)
+		}
+		authConfig := auth.Config{
+			NonResourceURL:     opts.AuthNonResourceURL,
+			ResourceAttributes: resourceAttributes,
+			CacheTTL:           opts.AuthCacheTTL,
+		}
+		if err := authConfig.Validate(); err != nil {
+			klog.Fatalf("Invalid auth delegation: %v", err)
+		}
+		if tlsConfig == nil {
+			klog.Warning("--auth-delegation is enabled without TLS, bearer tokens are sent in clear text")
+		}
+		delegator := auth.NewDelegator(clients.KubeClient(), authConfig)
+		protect = delegator.WithAuth
+		tenantHandler = metricshandler.NewTenantHandler(compression, delegator)
+	}
+
+	proc.StartReaper()
+
+	osMetricsRegistry := prometheus.NewRegistry()
+	osMetricsRegistry.Register(ocollectors.ResourcesPerScrapeMetric)
+	osMetricsRegistry.Register(ocollectors.ScrapeErrorTotalMetric)
+	osMetricsRegistry.Register(ocollectors.CollectorEnabledMetric)
+	osMetricsRegistry.Register(ocollectors.ListWatchRequestsMetric)
+	osMetricsRegistry.Register(ocollectors.ListDurationMetric)
+	osMetricsRegistry.Register(ocollectors.InitialListDurationMetric)
+	osMetricsRegistry.Register(ocollectors.WatchRestartsMetric)
+	osMetricsRegistry.Register(ocollectors.StoreObjectsMetric)
+	osMetricsRegistry.Register(ocollectors.WatchEventsMetric)
+	if opts.WatchEventsByNamespace {
+		osMetricsRegistry.Register(ocollectors.NamespaceWatchEventsMetric)
+	}
+	osMetricsRegistry.Register(metricshandler.CollectDurationMetric)
+	osMetricsRegistry.Register(metricshandler.CollectBytesMetric)
+	if opts.Config != "" {
+		osMetricsRegistry.Register(options.ConfigReloadsTotalMetric)
+		osMetricsRegistry.Register(options.ConfigLastReloadSuccessfulMetric)
+		osMetricsRegistry.Register(options.ConfigLastReloadSuccessTimestampMetric)
+	}
+	osMetricsRegistry.Register(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	osMetricsRegistry.Register(prometheus.NewGoCollector())
+	serverErrors := make(chan error, 2)
+
+	handler := metricshandler.New(compression)
+	b := ocollectors.NewBuilder(ctx).
+		WithClientFactory(clients).
+		WithWatchEventsByNamespace(opts.WatchEventsByNamespace).
+		WithMetadataOnlyWatches(opts.MetadataOnlyWatches).
+		WithListPageSize(opts.ListPageSize).
+		WithWatchList(opts.EnableWatchList)
+	if customResourceState != nil {
+		b.WithCustomResourceState(customResourceState)
+	}
+	var (
+		// buildMu serializes rebuilds and the shutdown of the collectors.
+		buildMu            sync.Mutex
+		shard, totalShards = opts.Shard, int32(opts.TotalShards)
+		// adminOverrides are the collectors enabled or disabled through the
+		// admin API. They take precedence over the settings until restart.
+		adminOverrides = map[string]bool{}
+	)
+	// buildCollectors applies the current settings and shard to the builder,
+	// which only rebuilds the collectors affected by a change, and serves the
+	// resulting collectors. buildMu must be held.
+	buildCollectors := func() {
+		if ctx.Err() != nil {
+			return
+		}
+		if totalShards > 1 {
+			klog.Infof("Using shard %d of %d", shard, totalShards)
+		}
+
+		settings.apply(b)
+		b.WithEnabledCollectors(withOverrides(settings.collectors, adminOverrides))
+		b.WithSharding(shard, totalShards)
+		collectors := b.Build()
+		namedCollectors := make([]metricshandler.NamedCollector, len(collectors))
+		for i, name := range b.CollectorNames() {
+			namedCollectors[i] = metricshandler.NamedCollector{Name: name, Collector: collectors[i]}
+		}
+		handler.SetCollectors(namedCollectors)
+		if tenantHandler != nil {
+			var tenantCollectors []metricshandler.TenantCollector
+			for _, c := range b.NamespacedCollectors() {
+				tenantCollectors = append(tenantCollectors, metricshandler.TenantCollector{Resource: c.Resource, Store: c.Store})
+			}
+			tenantHandler.SetCollectors(tenantCollectors)
+		}
+	}
+
+	buildMu.Lock()
+	if opts.AutoSharding {
+		sts, resolvedShard, resolvedTotalShards, err := ocollectors.ResolveSharding(ctx, clients.KubeClient(), opts.PodNamespace, opts.Pod)
+		if err != nil {
+			klog.Fatalf("Failed to resolve sharding: %v", err)
+		}
+		shard, totalShards = resolvedShard, resolvedTotalShards
+		go ocollectors.WatchStatefulSetReplicas(ctx, clients.KubeClient(), opts.PodNamespace, sts, totalShards, func(replicas int32) {
+			buildMu.Lock()
+			defer buildMu.Unlock()
+			totalShards = replicas
+			buildCollectors()
+		})
+	}
+	buildCollectors()
+	buildMu.Unlock()
+
+	// debugHandlers are served on the telemetry port by pattern.
+	debugHandlers := map[string]http.Handler{}
+	if opts.EnableAdminAPI {
+		adminHandler := admin.NewHandler(ocollectors.AvailableCollectors(), b.Details, func(name string, enabled bool) {
+			buildMu.Lock()
+			defer buildMu.Unlock()
+			adminOverrides[name] = enabled
+			buildCollectors()
+		})
+		debugHandlers[admin.CollectorsPath] = adminHandler
+		debugHandlers[admin.CollectorsPath+"/"] = adminHandler
+	}
+	if opts.EnableDebugObjects {
+		debugHandlers[admin.ObjectsPath] = admin.NewObjectsHandler(b.Object)
+	}
+	telemetry := telemetryServer(osMetricsRegistry, debugHandlers, opts.TelemetryHost, opts.TelemetryPort, tlsConfig, protect)
+	go func() { serverErrors <- listenAndServe(telemetry) }()
+
+	if opts.Config != "" {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+		options.WatchConfig(ctx, opts.Config, options.DefaultConfigReloadInterval, hup, func() error {
+			reloaded, err := opts.Reload()
+			if err != nil {
+				return err
+			}
+			for _, name := range opts.RestartRequired(reloaded) {
+				klog.Warningf("Option %s changed, it takes effect after a restart", name)
+			}
+			reloadedSettings, err := newCollectorSettings(reloaded)
+			if err != nil {
+				return err
+			}
+
+			buildMu.Lock()
+			defer buildMu.Unlock()
+			settings = reloadedSettings
+			buildCollectors()
+			return nil
+		})
+	}
+
+	var tenant http.Handler
+	if tenantHandler != nil {
+		tenant = tenantHandler
+	}
+	metrics := metricsServer(handler, tenant, b.Status, opts.Host, opts.Port, opts.LivezMaxStaleness, tlsConfig, protect)
+	go func() { serverErrors <- listenAndServe(metrics) }()
+
+	exitCode := 0
+	select {
+	case <-signals.Done():
+		klog.Info("Received termination signal, shutting down")
+	case err := <-serverErrors:
+		klog.Errorf("Server failed, shutting down: %v", err)
+		exitCode = 1
+	}
+	stopSignals()
+
+	// Let in-flight scrapes finish before the collectors go away.
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), opts.ShutdownGracePeriod)
+	defer cancelShutdown()
+	var wg sync.WaitGroup
+	for _, server := range []*http.Server{metrics, telemetry} {
+		wg.Add(1)
+		go func(server *http.Server) {
+			defer wg.Done()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				klog.Errorf("Failed to shut down server %s gracefully: %v", server.Addr, err)
+			}
+		}(server)
+	}
+	wg.Wait()
+
+	cancel()
+	buildMu.Lock()
+	b.Stop()
+	buildMu.Unlock()
+	klog.Info("Shutdown complete")
+	klog.Flush()
+	os.Exit(exitCode)
+}
+
+// withOverrides returns the given collectors with the overrides applied.
+func withOverrides(collectors []string, overrides map[string]bool) []string {
+	enabled := map[string]bool{}
+	for _, c := range collectors {
+		enabled[c] = true
+	}
+	for c, e := range overrides {
+		enabled[c] = e
+	}
+
+	var result []string
+	for c, e := range enabled {
+		if e {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// render implements the render subcommand. It writes the metrics of the
+// manifests in --from-dir to stdout instead of watching a cluster.
+func render() error {
+	opts := options.NewOptions()
+	opts.AddRenderFlags()
+	if err := opts.Parse(); err != nil {
+		return err
+	}
+	if opts.Help {
+		opts.Usage()
+		return nil
+	}
+	if opts.FromDir == "" {
+		return fmt.Errorf("--from-dir is required")
+	}
+
+	settings, err := newCollectorSettings(opts)
+	if err != nil {
+		return err
+	}
+	var customResourceState *ocollectors.CustomResourceStateConfig
+	if opts.CustomResourceStateConfigFile != "" {
+		customResourceState, err = ocollectors.LoadCustomResourceStateConfig(opts.CustomResourceStateConfigFile)
+		if err != nil {
+			return fmt.Errorf("failed to load custom resource state config: %w", err)
+		}
+	}
+	objects, err := ocollectors.ReadObjects(opts.FromDir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", opts.FromDir, err)
+	}
+	klog.Infof("Rendering %d objects of %s", len(objects), opts.FromDir)
+
+	b := ocollectors.NewBuilder(context.Background())
+	settings.apply(b)
+	if customResourceState != nil {
+		b.WithCustomResourceState(customResourceState)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	if err := b.Render(out, objects); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// collectorSettings are the options the collectors are built with which can
+// be changed by reloading the config file.
+type collectorSettings struct {
+	collectors        []string
+	namespaces        koptions.NamespaceList
+	namespaceSelector labels.Selector
+	namespaceDenylist koptions.NamespaceList
+	whiteBlackList    *whiteblacklist.WhiteBlackList
+	allowLabels       options.LabelsAllowList
+	allowAnnotations  options.LabelsAllowList
+}
+
+func newCollectorSettings(opts *options.Options) (*collectorSettings, error) {
+	s := &collectorSettings{
+		namespaces:        koptions.DefaultNamespaces,
+		namespaceDenylist: opts.NamespacesDenylist,
+		allowLabels:       opts.MetricLabelsAllowlist,
+		allowAnnotations:  opts.MetricAnnotationsAllowlist,
+	}
+
 	if len(opts.Collectors) == 0 {
 		klog.Info("Using default collectors")
-		collectorBuilder.WithEnabledCollectors(options.DefaultCollectors.AsSlice())
+		s.collectors = options.DefaultCollectors.AsSlice()
 	} else {
-		collectorBuilder.WithEnabledCollectors(opts.Collectors.AsSlice())
+		s.collectors = opts.Collectors.AsSlice()
 	}
 
 	if len(opts.Namespaces) == 0 {
 		klog.Info("Using all namespace")
-		collectorBuilder.WithNamespaces(koptions.DefaultNamespaces)
 	} else {
 		if opts.Namespaces.IsAllNamespaces() {
 			klog.Info("Using all namespace")
 		} else {
 			klog.Infof("Using %s namespaces", opts.Namespaces)
 		}
-		collectorBuilder.WithNamespaces(opts.Namespaces)
+		s.namespaces = opts.Namespaces
 	}
 
-	whiteBlackList, err := whiteblacklist.New(opts.MetricWhitelist, opts.MetricBlacklist)
+	var err error
+	s.namespaceSelector, err = labels.Parse(opts.NamespaceSelector)
 	if err != nil {
-		klog.Fatal(err)
+		return nil, fmt.Errorf("invalid namespace selector: %w", err)
+	}
+	if len(opts.NamespacesDenylist) > 0 {
+		klog.Infof("Excluding %s namespaces", opts.NamespacesDenylist)
 	}
 
-	klog.Infof("metric white- blacklisting: %v", whiteBlackList.Status())
-
-	collectorBuilder.WithWhiteBlackList(whiteBlackList)
-
-	proc.StartReaper()
+	if err := ocollectors.ValidateAllowLabels(opts.MetricLabelsAllowlist); err != nil {
+		return nil, err
+	}
+	if err := ocollectors.ValidateAllowAnnotations(opts.MetricAnnotationsAllowlist); err != nil {
+		return nil, err
+	}
 
+	s.whiteBlackList, err = whiteblacklist.New(opts.MetricWhitelist, opts.MetricBlacklist)
 	if err != nil {
-		klog.Fatalf("Failed to create client: %v", err)
+		return nil, err
 	}
+	klog.Infof("metric white- blacklisting: %v", s.whiteBlackList.Status())
 
-	osMetricsRegistry := prometheus.NewRegistry()
-	osMetricsRegistry.Register(ocollectors.ResourcesPerScrapeMetric)
-	osMetricsRegistry.Register(ocollectors.ScrapeErrorTotalMetric)
-	osMetricsRegistry.Register(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
-	osMetricsRegistry.Register(prometheus.NewGoCollector())
-	go telemetryServer(osMetricsRegistry, opts.TelemetryHost, opts.TelemetryPort)
-
-	collectors := collectorBuilder.Build()
+	return s, nil
+}
 
-	serveMetrics(collectors, opts.Host, opts.Port, opts.EnableGZIPEncoding)
+// apply configures the builder with the settings.
+func (s *collectorSettings) apply(b *ocollectors.Builder) {
+	b.WithEnabledCollectors(s.collectors).
+		WithNamespaces(s.namespaces).
+		WithNamespaceSelector(s.namespaceSelector).
+		WithNamespaceDenylist(s.namespaceDenylist).
+		WithWhiteBlackList(s.whiteBlackList).
+		WithAllowLabels(s.allowLabels).
+		WithAllowAnnotations(s.allowAnnotations)
 }
-func telemetryServer(registry prometheus.Gatherer, host string, port int) {
+
+// telemetryServer returns the server of the self metrics.
+func telemetryServer(registry prometheus.Gatherer, debugHandlers map[string]http.Handler, host string, port int, tlsConfig *tls.Config, protect func(http.Handler) http.Handler) *http.Server {
 	// Address to listen on for web interface and telemetry
 	listenAddress := net.JoinHostPort(host, strconv.Itoa(port))
 
@@ -116,7 +506,11 @@ func telemetryServer(registry prometheus.Gatherer, host string, port int) {
 	mux := http.NewServeMux()
 
 	// Add metricsPath
-	mux.Handle(metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorLog: promLogger{}}))
+	mux.Handle(metricsPath, protect(promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorLog: promLogger{}})))
+	// Add the admin and debug endpoints
+	for pattern, handler := range debugHandlers {
+		mux.Handle(pattern, protect(handler))
+	}
 	// Add index
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
@@ -129,11 +523,11 @@ func telemetryServer(registry prometheus.Gatherer, host string, port int) {
              </body>
              </html>`))
 	})
-	log.Fatal(http.ListenAndServe(listenAddress, mux))
+	return &http.Server{Addr: listenAddress, Handler: mux, TLSConfig: tlsConfig}
 }
 
-// TODO: How about accepting an interface Collector instead?
-func serveMetrics(collectors []*kcollectors.Collector, host string, port int, enableGZIPEncoding bool) {
+// metricsServer returns the server of the metrics of the collectors.
+func metricsServer(handler http.Handler, tenantHandler http.Handler, status func() []ocollectors.CollectorStatus, host string, port int, livezMaxStaleness time.Duration, tlsConfig *tls.Config, protect func(http.Handler) http.Handler) *http.Server {
 	// Address to listen on for web interface and telemetry
 	listenAddress := net.JoinHostPort(host, strconv.Itoa(port))
 
@@ -141,20 +535,48 @@ func serveMetrics(collectors []*kcollectors.Collector, host string, port int, en
 
 	mux := http.NewServeMux()
 
-	// TODO: This doesn't belong into serveMetrics
-	mux.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
-	mux.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
-	mux.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
-	mux.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
-	mux.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
+	// TODO: This doesn't belong into metricsServer
+	mux.Handle("/debug/pprof/", protect(http.HandlerFunc(pprof.Index)))
+	mux.Handle("/debug/pprof/cmdline", protect(http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle("/debug/pprof/profile", protect(http.HandlerFunc(pprof.Profile)))
+	mux.Handle("/debug/pprof/symbol", protect(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("/debug/pprof/trace", protect(http.HandlerFunc(pprof.Trace)))
 
 	// Add metricsPath
-	mux.Handle(metricsPath, &metricHandler{collectors, enableGZIPEncoding})
+	mux.Handle(metricsPath, protect(handler))
+	// Add tenantMetricsPath, it authorizes requests itself
+	if tenantHandler != nil {
+		mux.Handle(tenantMetricsPath, tenantHandler)
+	}
 	// Add healthzPath
 	mux.HandleFunc(healthzPath, func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
 		w.Write([]byte("ok"))
 	})
+	// Add readyzPath
+	mux.Handle(readyzPath, healthHandler("readyz", status, func(s ocollectors.CollectorStatus) (bool, string) {
+		switch {
+		case s.DiscoveryFailed:
+			return false, "API discovery failed"
+		case !s.Started:
+			return true, "skipped, API not served"
+		case !s.Synced:
+			return false, "not synced"
+		}
+		return true, "ok"
+	}))
+	// Add livezPath
+	mux.Handle(livezPath, healthHandler("livez", status, func(s ocollectors.CollectorStatus) (bool, string) {
+		switch {
+		case s.DiscoveryFailed:
+			return true, "skipped, API discovery failed"
+		case !s.Started:
+			return true, "skipped, API not served"
+		case livezMaxStaleness > 0 && time.Since(s.LastSuccess) > livezMaxStaleness:
+			return false, fmt.Sprintf("no successful list or watch since %s", s.LastSuccess.Format(time.RFC3339))
+		}
+		return true, "ok"
+	}))
 	// Add index
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
@@ -164,44 +586,59 @@ func serveMetrics(collectors []*kcollectors.Collector, host string, port int, en
 			 <ul>
              <li><a href='` + metricsPath + `'>metrics</a></li>
              <li><a href='` + healthzPath + `'>healthz</a></li>
+             <li><a href='` + readyzPath + `?verbose'>readyz</a></li>
+             <li><a href='` + livezPath + `?verbose'>livez</a></li>
 			 </ul>
              </body>
              </html>`))
 	})
-	log.Fatal(http.ListenAndServe(listenAddress, mux))
+	return &http.Server{Addr: listenAddress, Handler: mux, TLSConfig: tlsConfig}
 }
 
-type metricHandler struct {
-	collectors         []*kcollectors.Collector
-	enableGZIPEncoding bool
+// listenAndServe serves HTTPS if the server has a TLS configuration and plain
+// HTTP otherwise. It returns nil once the server is shut down.
+func listenAndServe(server *http.Server) error {
+	var err error
+	if server.TLSConfig == nil {
+		err = server.ListenAndServe()
+	} else {
+		// The certificates are taken from the TLS configuration.
+		err = server.ListenAndServeTLS("", "")
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
 }
 
-func (m *metricHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	resHeader := w.Header()
-	var writer io.Writer = w
-
-	resHeader.Set("Content-Type", `text/plain; version=`+"0.0.4")
-
-	if m.enableGZIPEncoding {
-		// Gzip response if requested. Taken from
-		// github.com/prometheus/client_golang/prometheus/promhttp.decorateWriter.
-		reqHeader := r.Header.Get("Accept-Encoding")
-		parts := strings.Split(reqHeader, ",")
-		for _, part := range parts {
-			part = strings.TrimSpace(part)
-			if part == "gzip" || strings.HasPrefix(part, "gzip;") {
-				writer = gzip.NewWriter(writer)
-				resHeader.Set("Content-Encoding", "gzip")
+// healthHandler runs the given check against every collector. It responds
+// with 200 if all checks pass and 503 otherwise. The "verbose" query parameter
+// adds the result of every single check to the response.
+func healthHandler(name string, status func() []ocollectors.CollectorStatus, check func(ocollectors.CollectorStatus) (bool, string)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failed := false
+		var details strings.Builder
+		for _, s := range status() {
+			ok, msg := check(s)
+			if ok {
+				fmt.Fprintf(&details, "[+]%s %s\n", s.Name, msg)
+			} else {
+				failed = true
+				fmt.Fprintf(&details, "[-]%s %s\n", s.Name, msg)
 			}
 		}
-	}
-
-	for _, c := range m.collectors {
-		c.Collect(w)
-	}
 
-	// In case we gziped the response, we have to close the writer.
-	if closer, ok := writer.(io.Closer); ok {
-		closer.Close()
-	}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if failed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if _, verbose := r.URL.Query()["verbose"]; verbose || failed {
+			w.Write([]byte(details.String()))
+		}
+		if failed {
+			fmt.Fprintf(w, "%s check failed\n", name)
+			return
+		}
+		fmt.Fprintf(w, "%s check passed\n", name)
+	})
 }
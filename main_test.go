@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ocollectors "github.com/openshift/openshift-state-metrics/pkg/collectors"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	stale := time.Now().Add(-time.Hour)
+	cases := []struct {
+		name   string
+		status ocollectors.CollectorStatus
+		path   string
+		code   int
+		body   string
+	}{
+		{
+			name:   "ready",
+			status: ocollectors.CollectorStatus{Name: "routes", Started: true, Synced: true, LastSuccess: time.Now()},
+			path:   "/readyz",
+			code:   http.StatusOK,
+			body:   "readyz check passed\n",
+		},
+		{
+			name:   "ready verbose",
+			status: ocollectors.CollectorStatus{Name: "routes", Started: true, Synced: true, LastSuccess: time.Now()},
+			path:   "/readyz?verbose",
+			code:   http.StatusOK,
+			body:   "[+]routes ok\nreadyz check passed\n",
+		},
+		{
+			name:   "not synced",
+			status: ocollectors.CollectorStatus{Name: "routes", Started: true, LastSuccess: time.Now()},
+			path:   "/readyz",
+			code:   http.StatusServiceUnavailable,
+			body:   "[-]routes not synced\nreadyz check failed\n",
+		},
+		{
+			name:   "API not served",
+			status: ocollectors.CollectorStatus{Name: "builds"},
+			path:   "/readyz?verbose",
+			code:   http.StatusOK,
+			body:   "[+]builds skipped, API not served\nreadyz check passed\n",
+		},
+		{
+			name:   "API discovery failed",
+			status: ocollectors.CollectorStatus{Name: "builds", DiscoveryFailed: true},
+			path:   "/readyz",
+			code:   http.StatusServiceUnavailable,
+			body:   "[-]builds API discovery failed\nreadyz check failed\n",
+		},
+		{
+			name:   "live",
+			status: ocollectors.CollectorStatus{Name: "routes", Started: true, Synced: true, LastSuccess: time.Now()},
+			path:   "/livez?verbose",
+			code:   http.StatusOK,
+			body:   "[+]routes ok\nlivez check passed\n",
+		},
+		{
+			name:   "stale",
+			status: ocollectors.CollectorStatus{Name: "routes", Started: true, Synced: true, LastSuccess: stale},
+			path:   "/livez",
+			code:   http.StatusServiceUnavailable,
+			body:   "[-]routes no successful list or watch since " + stale.Format(time.RFC3339) + "\nlivez check failed\n",
+		},
+		{
+			name:   "live while API discovery failed",
+			status: ocollectors.CollectorStatus{Name: "builds", DiscoveryFailed: true},
+			path:   "/livez?verbose",
+			code:   http.StatusOK,
+			body:   "[+]builds skipped, API discovery failed\nlivez check passed\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			status := func() []ocollectors.CollectorStatus { return []ocollectors.CollectorStatus{c.status} }
+			identity := func(h http.Handler) http.Handler { return h }
+			server := metricsServer(http.NotFoundHandler(), nil, status, "127.0.0.1", 0, time.Minute, nil, identity)
+
+			w := httptest.NewRecorder()
+			server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.code {
+				t.Errorf("expected status code %d, got %d", c.code, w.Code)
+			}
+			if got := w.Body.String(); got != c.body {
+				t.Errorf("expected body %q, got %q", c.body, got)
+			}
+		})
+	}
+}
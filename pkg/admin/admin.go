@@ -0,0 +1,129 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/openshift-state-metrics/pkg/collectors"
+)
+
+// CollectorsPath is the path of the list of collectors. A single collector is
+// served below it, e.g. /admin/collectors/routes.
+const CollectorsPath = "/admin/collectors"
+
+// Collector is a collector as served by the admin API. Disabled collectors
+// only have a name.
+type Collector struct {
+	collectors.CollectorDetails
+	Enabled bool `json:"enabled"`
+}
+
+// collectorUpdate is the body of a POST to a single collector.
+type collectorUpdate struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// Handler serves the collectors as JSON. POSTing {"enabled": false} or
+// {"enabled": true} to a built-in collector disables or enables it at runtime.
+type Handler struct {
+	available  []string
+	details    func() []collectors.CollectorDetails
+	setEnabled func(name string, enabled bool)
+}
+
+// NewHandler returns a new Handler. available are the names of the collectors
+// which can be enabled and disabled, details returns the collectors which
+// currently run and setEnabled enables or disables a collector.
+func NewHandler(available []string, details func() []collectors.CollectorDetails, setEnabled func(name string, enabled bool)) *Handler {
+	return &Handler{available: available, details: details, setEnabled: setEnabled}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, CollectorsPath), "/")
+	if name == "" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, h.collectors())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if !h.isAvailable(name) {
+			http.Error(w, fmt.Sprintf("Unknown collector %q", name), http.StatusNotFound)
+			return
+		}
+		var update collectorUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil || update.Enabled == nil {
+			http.Error(w, `Expected a body like {"enabled": false}`, http.StatusBadRequest)
+			return
+		}
+		klog.Infof("Collector %s is %s through the admin API", name, enabledString(*update.Enabled))
+		h.setEnabled(name, *update.Enabled)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	for _, c := range h.collectors() {
+		if c.Name == name {
+			writeJSON(w, c)
+			return
+		}
+	}
+	http.Error(w, fmt.Sprintf("Unknown collector %q", name), http.StatusNotFound)
+}
+
+// collectors returns the available collectors and the running ones, sorted by
+// name.
+func (h *Handler) collectors() []Collector {
+	byName := map[string]Collector{}
+	for _, name := range h.available {
+		byName[name] = Collector{CollectorDetails: collectors.CollectorDetails{
+			CollectorStatus: collectors.CollectorStatus{Name: name},
+		}}
+	}
+	for _, d := range h.details() {
+		byName[d.Name] = Collector{CollectorDetails: d, Enabled: true}
+	}
+
+	list := make([]Collector, 0, len(byName))
+	for _, c := range byName {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (h *Handler) isAvailable(name string) bool {
+	for _, a := range h.available {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+func enabledString(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("Failed to write admin API response: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openshift/openshift-state-metrics/pkg/collectors"
+)
+
+// fakeCollectors runs the enabled collectors, each holding a single object.
+type fakeCollectors struct {
+	enabled map[string]bool
+}
+
+func (f *fakeCollectors) details() []collectors.CollectorDetails {
+	var details []collectors.CollectorDetails
+	for _, name := range []string{"builds", "routes", "custom"} {
+		if f.enabled[name] {
+			details = append(details, collectors.CollectorDetails{
+				CollectorStatus: collectors.CollectorStatus{Name: name, Started: true, Synced: true},
+				Namespaces:      []string{""},
+				Objects:         1,
+				Series:          3,
+			})
+		}
+	}
+	return details
+}
+
+func (f *fakeCollectors) setEnabled(name string, enabled bool) {
+	f.enabled[name] = enabled
+}
+
+func serve(t *testing.T, h http.Handler, method string, path string, body string) (int, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w.Code, w.Body.String()
+}
+
+func TestHandler(t *testing.T) {
+	f := &fakeCollectors{enabled: map[string]bool{"routes": true, "custom": true}}
+	h := NewHandler([]string{"builds", "routes"}, f.details, f.setEnabled)
+
+	code, body := serve(t, h, http.MethodGet, CollectorsPath, "")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, body)
+	}
+	var list []Collector
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("expected 3 collectors, got %s", body)
+	}
+	if c := list[0]; c.Name != "builds" || c.Enabled || c.Objects != 0 {
+		t.Errorf("expected builds to be disabled, got %+v", c)
+	}
+	if c := list[2]; c.Name != "routes" || !c.Enabled || c.Objects != 1 || c.Series != 3 || !c.Synced {
+		t.Errorf("expected routes to be enabled with 1 object and 3 series, got %+v", c)
+	}
+
+	code, body = serve(t, h, http.MethodPost, CollectorsPath+"/routes", `{"enabled": false}`)
+	if code != http.StatusOK || f.enabled["routes"] {
+		t.Fatalf("expected routes to be disabled, got %d: %s", code, body)
+	}
+	var c Collector
+	if err := json.Unmarshal([]byte(body), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "routes" || c.Enabled {
+		t.Errorf("expected the disabled collector to be returned, got %s", body)
+	}
+
+	code, body = serve(t, h, http.MethodPost, CollectorsPath+"/builds", `{"enabled": true}`)
+	if code != http.StatusOK || !f.enabled["builds"] || !strings.Contains(body, `"enabled":true`) {
+		t.Errorf("expected builds to be enabled, got %d: %s", code, body)
+	}
+
+	for _, test := range []struct {
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{http.MethodGet, CollectorsPath + "/custom", "", http.StatusOK},
+		{http.MethodGet, CollectorsPath + "/unknown", "", http.StatusNotFound},
+		{http.MethodPost, CollectorsPath + "/unknown", `{"enabled": false}`, http.StatusNotFound},
+		// Only built-in collectors can be enabled and disabled.
+		{http.MethodPost, CollectorsPath + "/custom", `{"enabled": false}`, http.StatusNotFound},
+		{http.MethodPost, CollectorsPath + "/routes", `{}`, http.StatusBadRequest},
+		{http.MethodPost, CollectorsPath + "/routes", `enabled`, http.StatusBadRequest},
+		{http.MethodPost, CollectorsPath, `{"enabled": false}`, http.StatusMethodNotAllowed},
+		{http.MethodDelete, CollectorsPath + "/routes", "", http.StatusMethodNotAllowed},
+	} {
+		if code, body := serve(t, h, test.method, test.path, test.body); code != test.code {
+			t.Errorf("%s %s: expected %d, got %d: %s", test.method, test.path, test.code, code, body)
+		}
+	}
+}
+
+func TestObjectsHandler(t *testing.T) {
+	h := NewObjectsHandler(func(collector string, namespace string, name string) (collectors.ObjectDetails, bool, error) {
+		if collector != "routes" {
+			return collectors.ObjectDetails{}, false, fmt.Errorf("%w %q", collectors.ErrUnknownCollector, collector)
+		}
+		if namespace != "ns1" || name != "r1" {
+			return collectors.ObjectDetails{}, false, nil
+		}
+		return collectors.ObjectDetails{Key: "ns1/r1", ResourceVersion: "42", Families: []collectors.FamilyDetails{{
+			Name:    "openshift_route_info",
+			Type:    "gauge",
+			Metrics: []collectors.MetricDetails{{Labels: map[string]string{"route": "r1"}, Value: "1"}},
+		}}}, true, nil
+	})
+
+	code, body := serve(t, h, http.MethodGet, ObjectsPath+"?collector=routes&namespace=ns1&name=r1", "")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, body)
+	}
+	var object collectors.ObjectDetails
+	if err := json.Unmarshal([]byte(body), &object); err != nil {
+		t.Fatal(err)
+	}
+	if object.Key != "ns1/r1" || object.ResourceVersion != "42" || len(object.Families) != 1 || object.Families[0].Metrics[0].Value != "1" {
+		t.Errorf("expected the metrics of route ns1/r1, got %s", body)
+	}
+
+	for _, tc := range []struct {
+		method string
+		query  string
+		code   int
+	}{
+		{http.MethodGet, "?collector=routes&namespace=ns1", http.StatusBadRequest},
+		{http.MethodGet, "?namespace=ns1&name=r1", http.StatusBadRequest},
+		{http.MethodGet, "?collector=builds&namespace=ns1&name=r1", http.StatusNotFound},
+		{http.MethodGet, "?collector=routes&namespace=ns2&name=r1", http.StatusNotFound},
+		{http.MethodPost, "?collector=routes&namespace=ns1&name=r1", http.StatusMethodNotAllowed},
+	} {
+		if code, body := serve(t, h, tc.method, ObjectsPath+tc.query, ""); code != tc.code {
+			t.Errorf("expected %d for %s %s, got %d: %s", tc.code, tc.method, tc.query, code, body)
+		}
+	}
+}
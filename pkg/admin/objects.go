@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/openshift-state-metrics/pkg/collectors"
+)
+
+// ObjectsPath is the path of the metrics generated for single objects.
+const ObjectsPath = "/debug/objects"
+
+// ObjectsHandler serves the metrics a collector generated for a single object
+// as JSON, along with the key and the resourceVersion of the object when the
+// collector last processed it, e.g.
+// /debug/objects?collector=routes&namespace=ns1&name=r1. The namespace is
+// left out for cluster-scoped objects.
+type ObjectsHandler struct {
+	object func(collector string, namespace string, name string) (collectors.ObjectDetails, bool, error)
+}
+
+// NewObjectsHandler returns a new ObjectsHandler which looks objects up with
+// the given function, usually Builder.Object.
+func NewObjectsHandler(object func(collector string, namespace string, name string) (collectors.ObjectDetails, bool, error)) *ObjectsHandler {
+	return &ObjectsHandler{object: object}
+}
+
+func (h *ObjectsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	collector, namespace, name := query.Get("collector"), query.Get("namespace"), query.Get("name")
+	if collector == "" || name == "" {
+		http.Error(w, "The collector and name parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	object, ok, err := h.object(collector, namespace, name)
+	switch {
+	case errors.Is(err, collectors.ErrUnknownCollector):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		klog.Errorf("Failed to look up object %s/%s of collector %s: %v", namespace, name, collector, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case !ok:
+		http.Error(w, fmt.Sprintf("Collector %s holds no object %s in namespace %q", collector, name, namespace), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, object)
+}
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// cacheSize bounds the number of cached authentication and authorization
+// decisions each.
+const cacheSize = 4096
+
+// Config configures how requests are authorized.
+type Config struct {
+	// NonResourceURL is the non-resource URL access is checked for. The path
+	// of the request is used if it is empty.
+	NonResourceURL string
+	// ResourceAttributes, if set, are checked instead of a non-resource URL,
+	// e.g. the metrics subresource of the openshift-state-metrics service.
+	// The verb is taken from the request.
+	ResourceAttributes *authorizationv1.ResourceAttributes
+	// CacheTTL is how long authentication and authorization decisions are
+	// cached. They are not cached if it is 0.
+	CacheTTL time.Duration
+}
+
+// Validate checks that at most one kind of attributes is set.
+func (c Config) Validate() error {
+	if c.NonResourceURL != "" && c.ResourceAttributes != nil {
+		return fmt.Errorf("a non-resource URL and resource attributes are mutually exclusive")
+	}
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("the cache TTL must not be negative")
+	}
+	return nil
+}
+
+// ParseResourceAttributes parses resource attributes given as a map, e.g.
+// {"namespace": "openshift-monitoring", "resource": "services",
+// "subresource": "metrics", "name": "openshift-state-metrics"}. It returns
+// nil for an empty map.
+func ParseResourceAttributes(m map[string]string) (*authorizationv1.ResourceAttributes, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	attrs := &authorizationv1.ResourceAttributes{}
+	fields := map[string]*string{
+		"namespace":   &attrs.Namespace,
+		"apiGroup":    &attrs.Group,
+		"apiVersion":  &attrs.Version,
+		"resource":    &attrs.Resource,
+		"subresource": &attrs.Subresource,
+		"name":        &attrs.Name,
+	}
+	for k, v := range m {
+		field, ok := fields[k]
+		if !ok {
+			return nil, fmt.Errorf("unknown resource attribute %q, supported are namespace, apiGroup, apiVersion, resource, subresource and name", k)
+		}
+		*field = v
+	}
+	if attrs.Resource == "" {
+		return nil, fmt.Errorf("the resource attribute is required")
+	}
+	return attrs, nil
+}
+
+// User is an authenticated user.
+type User struct {
+	Name   string
+	UID    string
+	Groups []string
+	Extra  map[string][]string
+}
+
+// Delegator authenticates requests with TokenReviews or client certificates
+// and authorizes them with SubjectAccessReviews against the apiserver.
+type Delegator struct {
+	client kubernetes.Interface
+	config Config
+
+	authn *cache.LRUExpireCache
+	authz *cache.LRUExpireCache
+}
+
+// NewDelegator returns a Delegator sending reviews with the given client.
+func NewDelegator(client kubernetes.Interface, config Config) *Delegator {
+	return &Delegator{
+		client: client,
+		config: config,
+		authn:  cache.NewLRUExpireCache(cacheSize),
+		authz:  cache.NewLRUExpireCache(cacheSize),
+	}
+}
+
+// Authenticate returns the user of the request. Verified client certificates
+// take precedence over bearer tokens. It returns nil if the request is not
+// authenticated.
+func (d *Delegator) Authenticate(r *http.Request) (*User, error) {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		cert := r.TLS.VerifiedChains[0][0]
+		return &User{Name: cert.Subject.CommonName, Groups: cert.Subject.Organization}, nil
+	}
+
+	token, ok := bearerToken(r)
+	if !ok {
+		return nil, nil
+	}
+	key := sha256.Sum256([]byte(token))
+	if u, ok := d.authn.Get(key); ok {
+		return u.(*User), nil
+	}
+
+	review, err := d.client.AuthenticationV1().TokenReviews().Create(r.Context(), &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var u *User
+	if review.Status.Authenticated {
+		u = &User{
+			Name:   review.Status.User.Username,
+			UID:    review.Status.User.UID,
+			Groups: review.Status.User.Groups,
+			Extra:  map[string][]string{},
+		}
+		for k, v := range review.Status.User.Extra {
+			u.Extra[k] = v
+		}
+	}
+	d.cache(d.authn, key, u)
+	return u, nil
+}
+
+// Authorize returns whether the user may access the given resource or
+// non-resource URL, and the reason given by the apiserver.
+func (d *Delegator) Authorize(ctx context.Context, u *User, verb string, resource *authorizationv1.ResourceAttributes, nonResourceURL string) (bool, string, error) {
+	spec := authorizationv1.SubjectAccessReviewSpec{
+		User:   u.Name,
+		UID:    u.UID,
+		Groups: u.Groups,
+	}
+	if len(u.Extra) > 0 {
+		spec.Extra = map[string]authorizationv1.ExtraValue{}
+		for k, v := range u.Extra {
+			spec.Extra[k] = v
+		}
+	}
+	if resource != nil {
+		attrs := *resource
+		attrs.Verb = verb
+		spec.ResourceAttributes = &attrs
+	} else {
+		spec.NonResourceAttributes = &authorizationv1.NonResourceAttributes{Path: nonResourceURL, Verb: verb}
+	}
+
+	// Maps are marshalled with sorted keys, the key is stable.
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return false, "", err
+	}
+	key := string(raw)
+	if status, ok := d.authz.Get(key); ok {
+		s := status.(authorizationv1.SubjectAccessReviewStatus)
+		return s.Allowed, s.Reason, nil
+	}
+
+	review, err := d.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{Spec: spec}, metav1.CreateOptions{})
+	if err != nil {
+		return false, "", err
+	}
+	d.cache(d.authz, key, review.Status)
+	return review.Status.Allowed, review.Status.Reason, nil
+}
+
+func (d *Delegator) cache(c *cache.LRUExpireCache, key, value interface{}) {
+	if d.config.CacheTTL > 0 {
+		c.Add(key, value, d.config.CacheTTL)
+	}
+}
+
+// WithAuth returns a handler only passing authenticated and authorized
+// requests on to the given handler. Other requests get 401 or 403.
+func (d *Delegator) WithAuth(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, err := d.Authenticate(r)
+		if err != nil {
+			klog.Errorf("Failed to authenticate request to %s: %v", r.URL.Path, err)
+		}
+		if u == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		nonResourceURL := d.config.NonResourceURL
+		if nonResourceURL == "" {
+			nonResourceURL = r.URL.Path
+		}
+		verb := RequestVerb(r)
+		allowed, reason, err := d.Authorize(r.Context(), u, verb, d.config.ResourceAttributes, nonResourceURL)
+		if err != nil {
+			klog.Errorf("Failed to authorize user %q: %v", u.Name, err)
+			http.Error(w, "Authorization error", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			klog.V(2).Infof("Forbidden user=%q groups=[%s] verb=%q path=%q: %s", u.Name, strings.Join(u.Groups, ","), verb, r.URL.Path, reason)
+			http.Error(w, fmt.Sprintf("Forbidden (user=%s, verb=%s, %s)", u.Name, verb, d.describe(nonResourceURL)), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// describe names what access was checked for in error messages.
+func (d *Delegator) describe(nonResourceURL string) string {
+	a := d.config.ResourceAttributes
+	if a == nil {
+		return "url=" + nonResourceURL
+	}
+	parts := []string{"resource=" + a.Resource}
+	for _, kv := range [][2]string{{"subresource", a.Subresource}, {"namespace", a.Namespace}, {"name", a.Name}} {
+		if kv[1] != "" {
+			parts = append(parts, kv[0]+"="+kv[1])
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// RequestVerb maps the method of an HTTP request to an authorization verb.
+func RequestVerb(r *http.Request) string {
+	switch r.Method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return "get"
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// fakeAPIServer answers TokenReviews for the token "prometheus-token" and
+// allows prometheus to get /metrics and the metrics subresource of services.
+type fakeAPIServer struct {
+	tokenReviews  atomic.Int32
+	accessReviews atomic.Int32
+	lastReview    atomic.Pointer[authorizationv1.SubjectAccessReviewSpec]
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/apis/authentication.k8s.io/v1/tokenreviews":
+		s.tokenReviews.Add(1)
+		var review authenticationv1.TokenReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch review.Spec.Token {
+		case "prometheus-token":
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: "system:serviceaccount:openshift-monitoring:prometheus-k8s", UID: "1", Groups: []string{"system:serviceaccounts"}}
+		case "other-token":
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: "developer"}
+		}
+		json.NewEncoder(w).Encode(review)
+	case "/apis/authorization.k8s.io/v1/subjectaccessreviews":
+		s.accessReviews.Add(1)
+		var review authorizationv1.SubjectAccessReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.lastReview.Store(&review.Spec)
+		if review.Spec.User == "system:serviceaccount:openshift-monitoring:prometheus-k8s" {
+			if a := review.Spec.NonResourceAttributes; a != nil && a.Path == "/metrics" && a.Verb == "get" {
+				review.Status.Allowed = true
+			}
+			if a := review.Spec.ResourceAttributes; a != nil && a.Resource == "services" && a.Subresource == "metrics" && a.Verb == "get" {
+				review.Status.Allowed = true
+			}
+		}
+		json.NewEncoder(w).Encode(review)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestDelegator(t *testing.T, config Config) (*Delegator, *fakeAPIServer) {
+	t.Helper()
+	apiserver := &fakeAPIServer{}
+	server := httptest.NewServer(apiserver)
+	t.Cleanup(server.Close)
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewDelegator(client, config), apiserver
+}
+
+func request(handler http.Handler, path, token string) int {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestWithAuth(t *testing.T) {
+	d, apiserver := newTestDelegator(t, Config{CacheTTL: time.Minute})
+	handler := d.WithAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		path  string
+		token string
+		code  int
+	}{
+		{"/metrics", "", http.StatusUnauthorized},
+		{"/metrics", "invalid-token", http.StatusUnauthorized},
+		{"/metrics", "other-token", http.StatusForbidden},
+		{"/metrics", "prometheus-token", http.StatusOK},
+		{"/debug/pprof/", "prometheus-token", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		if code := request(handler, test.path, test.token); code != test.code {
+			t.Errorf("%s with token %q: expected %d, got %d", test.path, test.token, test.code, code)
+		}
+	}
+
+	tokenReviews, accessReviews := apiserver.tokenReviews.Load(), apiserver.accessReviews.Load()
+	for _, test := range tests {
+		request(handler, test.path, test.token)
+	}
+	if got := apiserver.tokenReviews.Load(); got != tokenReviews {
+		t.Errorf("expected token reviews to be cached, got %d more", got-tokenReviews)
+	}
+	if got := apiserver.accessReviews.Load(); got != accessReviews {
+		t.Errorf("expected access reviews to be cached, got %d more", got-accessReviews)
+	}
+}
+
+func TestWithAuthNoCache(t *testing.T) {
+	d, apiserver := newTestDelegator(t, Config{})
+	handler := d.WithAuth(http.NotFoundHandler())
+
+	request(handler, "/metrics", "prometheus-token")
+	request(handler, "/metrics", "prometheus-token")
+	if got := apiserver.tokenReviews.Load(); got != 2 {
+		t.Errorf("expected 2 token reviews without cache, got %d", got)
+	}
+	if got := apiserver.accessReviews.Load(); got != 2 {
+		t.Errorf("expected 2 access reviews without cache, got %d", got)
+	}
+}
+
+func TestWithAuthResourceAttributes(t *testing.T) {
+	attrs, err := ParseResourceAttributes(map[string]string{
+		"namespace":   "openshift-monitoring",
+		"resource":    "services",
+		"subresource": "metrics",
+		"name":        "openshift-state-metrics",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, apiserver := newTestDelegator(t, Config{ResourceAttributes: attrs, CacheTTL: time.Minute})
+	handler := d.WithAuth(http.NotFoundHandler())
+
+	// Any path is checked against the resource attributes.
+	if code := request(handler, "/debug/pprof/", "prometheus-token"); code != http.StatusNotFound {
+		t.Errorf("expected the request to be passed on, got %d", code)
+	}
+	spec := apiserver.lastReview.Load()
+	if spec.NonResourceAttributes != nil || spec.ResourceAttributes == nil {
+		t.Fatalf("expected a review of resource attributes, got %+v", spec)
+	}
+	want := authorizationv1.ResourceAttributes{Namespace: "openshift-monitoring", Verb: "get", Resource: "services", Subresource: "metrics", Name: "openshift-state-metrics"}
+	if *spec.ResourceAttributes != want {
+		t.Errorf("expected resource attributes %+v, got %+v", want, *spec.ResourceAttributes)
+	}
+	if code := request(handler, "/metrics", "other-token"); code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", code)
+	}
+}
+
+func TestWithAuthNonResourceURL(t *testing.T) {
+	d, _ := newTestDelegator(t, Config{NonResourceURL: "/metrics"})
+	handler := d.WithAuth(http.NotFoundHandler())
+
+	if code := request(handler, "/debug/pprof/", "prometheus-token"); code != http.StatusNotFound {
+		t.Errorf("expected the configured URL to be authorized instead of the path, got %d", code)
+	}
+}
+
+func TestWithAuthAPIServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewDelegator(client, Config{}).WithAuth(http.NotFoundHandler())
+
+	if code := request(handler, "/metrics", "prometheus-token"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 if the token cannot be reviewed, got %d", code)
+	}
+}
+
+func TestParseResourceAttributes(t *testing.T) {
+	if attrs, err := ParseResourceAttributes(nil); attrs != nil || err != nil {
+		t.Errorf("expected no attributes, got %v, %v", attrs, err)
+	}
+	if _, err := ParseResourceAttributes(map[string]string{"resource": "services", "verb": "list"}); err == nil {
+		t.Errorf("expected an error for an unknown attribute")
+	}
+	if _, err := ParseResourceAttributes(map[string]string{"namespace": "openshift-monitoring"}); err == nil {
+		t.Errorf("expected an error without resource")
+	}
+}
@@ -11,13 +11,15 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/kube-state-metrics/pkg/metric"
 
-	"k8s.io/klog/v2"
-
 	v1 "github.com/openshift/api/build/v1"
 )
 
 var (
+	descBuildLabelsName          = "openshift_build_labels"
+	descBuildLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descBuildLabelsDefaultLabels = []string{"namespace", "build", "buildconfig", "strategy"}
+	descBuildAnnotationsName     = "openshift_build_annotations"
+	descBuildAnnotationsHelp     = "Kubernetes annotations converted to Prometheus labels."
 
 	buildMetricFamilies = []metric.FamilyGenerator{
 		{
@@ -53,9 +55,9 @@ var (
 			}),
 		},
 		{
-			Name: "openshift_build_labels",
+			Name: descBuildLabelsName,
 			Type: metric.MetricTypeGauge,
-			Help: "Kubernetes labels converted to Prometheus labels.",
+			Help: descBuildLabelsHelp,
 			GenerateFunc: wrapBuildFunc(func(b *v1.Build) metric.Family {
 				labelKeys, labelValues := kubeLabelsToPrometheusLabels(b.Labels)
 				return metric.Family{
@@ -69,6 +71,23 @@ var (
 				}
 			}),
 		},
+		{
+			Name: descBuildAnnotationsName,
+			Type: metric.MetricTypeGauge,
+			Help: descBuildAnnotationsHelp,
+			GenerateFunc: wrapBuildFunc(func(b *v1.Build) metric.Family {
+				annotationKeys, annotationValues := kubeAnnotationsToPrometheusLabels(b.Annotations)
+				return metric.Family{
+					Metrics: []*metric.Metric{
+						{
+							LabelKeys:   annotationKeys,
+							LabelValues: annotationValues,
+							Value:       1,
+						},
+					},
+				}
+			}),
+		},
 		{
 			Name: "openshift_build_status_phase_total",
 			Type: metric.MetricTypeGauge,
@@ -172,17 +191,13 @@ func determineBuildConfig(build *v1.Build) string {
 	return build.Labels["buildconfig"]
 }
 
-func createBuildListWatch(apiserver string, kubeconfig string, ns string) cache.ListWatch {
-	buildclient, err := createBuildClient(apiserver, kubeconfig)
-	if err != nil {
-		klog.Fatalf("cannot create build client: %v", err)
-	}
+func createBuildListWatch(clients ClientFactory, ns string) cache.ListWatch {
 	return cache.ListWatch{
 		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return buildclient.BuildV1().Builds(ns).List(context.TODO(), opts)
+			return clients.BuildClient().BuildV1().Builds(ns).List(context.TODO(), opts)
 		},
 		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return buildclient.BuildV1().Builds(ns).Watch(context.TODO(), opts)
+			return clients.BuildClient().BuildV1().Builds(ns).Watch(context.TODO(), opts)
 		},
 	}
 }
@@ -7,20 +7,17 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/kube-state-metrics/pkg/metric"
-	"k8s.io/kube-state-metrics/pkg/version"
-
-	"k8s.io/klog/v2"
 
 	v1 "github.com/openshift/api/build/v1"
-	buildclient "github.com/openshift/client-go/build/clientset/versioned"
 )
 
 var (
 	descBuildConfigLabelsName          = "openshift_buildconfig_labels"
 	descBuildConfigLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descBuildConfigLabelsDefaultLabels = []string{"namespace", "buildconfig"}
+	descBuildConfigAnnotationsName     = "openshift_buildconfig_annotations"
+	descBuildConfigAnnotationsHelp     = "Kubernetes annotations converted to Prometheus labels."
 
 	buildconfigMetricFamilies = []metric.FamilyGenerator{
 		{
@@ -73,6 +70,23 @@ var (
 				}
 			}),
 		},
+		{
+			Name: descBuildConfigAnnotationsName,
+			Type: metric.MetricTypeGauge,
+			Help: descBuildConfigAnnotationsHelp,
+			GenerateFunc: wrapBuildConfigFunc(func(d *v1.BuildConfig) metric.Family {
+				annotationKeys, annotationValues := kubeAnnotationsToPrometheusLabels(d.Annotations)
+				return metric.Family{
+					Metrics: []*metric.Metric{
+						{
+							Value:       1,
+							LabelKeys:   annotationKeys,
+							LabelValues: annotationValues,
+						},
+					},
+				}
+			}),
+		},
 		metric.FamilyGenerator{
 			Name: "openshift_buildconfig_status_latest_version",
 			Type: metric.MetricTypeGauge,
@@ -105,32 +119,13 @@ func wrapBuildConfigFunc(f func(config *v1.BuildConfig) metric.Family) func(inte
 	}
 }
 
-func createBuildConfigListWatch(apiserver string, kubeconfig string, ns string) cache.ListWatch {
-	buildclient, err := createBuildClient(apiserver, kubeconfig)
-	if err != nil {
-		klog.Fatalf("cannot create buildconfig client: %v", err)
-	}
+func createBuildConfigListWatch(clients ClientFactory, ns string) cache.ListWatch {
 	return cache.ListWatch{
 		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return buildclient.BuildV1().BuildConfigs(ns).List(context.TODO(), opts)
+			return clients.BuildClient().BuildV1().BuildConfigs(ns).List(context.TODO(), opts)
 		},
 		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return buildclient.BuildV1().BuildConfigs(ns).Watch(context.TODO(), opts)
+			return clients.BuildClient().BuildV1().BuildConfigs(ns).Watch(context.TODO(), opts)
 		},
 	}
 }
-
-func createBuildClient(apiserver string, kubeconfig string) (*buildclient.Clientset, error) {
-	config, err := clientcmd.BuildConfigFromFlags(apiserver, kubeconfig)
-	if err != nil {
-		return nil, err
-	}
-
-	config.UserAgent = version.GetVersion().String()
-	config.AcceptContentTypes = "application/vnd.kubernetes.protobuf,application/json"
-	config.ContentType = "application/vnd.kubernetes.protobuf"
-
-	client, err := buildclient.NewForConfig(config)
-	return client, err
-
-}
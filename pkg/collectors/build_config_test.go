@@ -20,6 +20,8 @@ func TestBuildConfigCollector(t *testing.T) {
 		# TYPE openshift_buildconfig_metadata_generation gauge
 		# HELP openshift_buildconfig_labels Kubernetes labels converted to Prometheus labels.
 		# TYPE openshift_buildconfig_labels gauge
+		# HELP openshift_buildconfig_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE openshift_buildconfig_annotations gauge
 		# HELP openshift_buildconfig_status_latest_version The latest version of buildconfig.
 		# TYPE openshift_buildconfig_status_latest_version gauge
 	`
@@ -33,6 +35,9 @@ func TestBuildConfigCollector(t *testing.T) {
 					Labels: map[string]string{
 						"app": "example1",
 					},
+					Annotations: map[string]string{
+						"example.com/owner": "team-a",
+					},
 					Generation: 21,
 				},
 				Status: v1.BuildConfigStatus{
@@ -43,10 +48,11 @@ func TestBuildConfigCollector(t *testing.T) {
 			Want: `
         openshift_buildconfig_created{buildconfig="build1",namespace="ns1"} 1.5e+09
         openshift_buildconfig_labels{buildconfig="build1",label_app="example1",namespace="ns1"} 1
+        openshift_buildconfig_annotations{buildconfig="build1",annotation_example_com_owner="team-a",namespace="ns1"} 1
         openshift_buildconfig_metadata_generation{buildconfig="build1",namespace="ns1"} 21
         openshift_buildconfig_status_latest_version{buildconfig="build1",namespace="ns1"} 1
 `,
-			MetricNames: []string{"openshift_buildconfig_labels", "openshift_buildconfig_status_latest_version", "openshift_buildconfig_created", "openshift_buildconfig_metadata_generation"},
+			MetricNames: []string{"openshift_buildconfig_labels", "openshift_buildconfig_annotations", "openshift_buildconfig_status_latest_version", "openshift_buildconfig_created", "openshift_buildconfig_metadata_generation"},
 		},
 	}
 
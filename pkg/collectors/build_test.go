@@ -20,6 +20,8 @@ func TestBuildCollector(t *testing.T) {
 		# TYPE openshift_build_metadata_generation_info gauge
 		# HELP openshift_build_labels Kubernetes labels converted to Prometheus labels.
 		# TYPE openshift_build_labels gauge
+		# HELP openshift_build_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE openshift_build_annotations gauge
 		# HELP openshift_build_status_phase_total The build phase
 		# TYPE openshift_build_status_phase_total gauge
 		# HELP openshift_build_start_timestamp_seconds Start time of the build
@@ -59,6 +61,7 @@ func TestBuildCollector(t *testing.T) {
      	openshift_build_completed_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 0
         openshift_build_created_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.5e+09
         openshift_build_labels{build="build1",buildconfig="build",label_app="example1",namespace="ns1",strategy="docker"} 1
+        openshift_build_annotations{build="build1",buildconfig="build",namespace="ns1",strategy="docker",annotation_openshift_io_build_config_name="build"} 1
         openshift_build_metadata_generation_info{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 21
         openshift_build_start_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 0
         openshift_build_status_phase_total{build="build1",build_phase="cancelled",buildconfig="build",namespace="ns1",strategy="docker"} 0
@@ -70,7 +73,7 @@ func TestBuildCollector(t *testing.T) {
         openshift_build_status_phase_total{build="build1",build_phase="running",buildconfig="build",namespace="ns1",strategy="docker"} 0
 `,
 
-			MetricNames: []string{"openshift_build_created_timestamp_seconds", "openshift_build_metadata_generation_info", "openshift_build_labels",
+			MetricNames: []string{"openshift_build_created_timestamp_seconds", "openshift_build_metadata_generation_info", "openshift_build_labels", "openshift_build_annotations",
 				"openshift_build_status_phase_total", "openshift_build_start_timestamp_seconds", "openshift_build_completed_timestamp_seconds", "openshift_build_duration_seconds"},
 		},
 		{
@@ -103,6 +106,7 @@ func TestBuildCollector(t *testing.T) {
      	openshift_build_completed_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 0
         openshift_build_created_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.5e+09
         openshift_build_labels{build="build1",buildconfig="build",label_app="example1",namespace="ns1",strategy="docker"} 1
+        openshift_build_annotations{build="build1",buildconfig="build",namespace="ns1",strategy="docker",annotation_openshift_io_build_config_name="build"} 1
         openshift_build_metadata_generation_info{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 21
         openshift_build_start_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 0
         openshift_build_status_phase_total{build="build1",build_phase="cancelled",buildconfig="build",namespace="ns1",strategy="docker"} 0
@@ -145,6 +149,7 @@ func TestBuildCollector(t *testing.T) {
 		openshift_build_completed_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 0
         openshift_build_created_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.5e+09
         openshift_build_labels{build="build1",buildconfig="build",label_app="example1",namespace="ns1",strategy="docker"} 1
+        openshift_build_annotations{build="build1",buildconfig="build",namespace="ns1",strategy="docker",annotation_openshift_io_build_config_name="build"} 1
         openshift_build_metadata_generation_info{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 21
         openshift_build_start_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.6e+09
         openshift_build_status_phase_total{build="build1",build_phase="cancelled",buildconfig="build",namespace="ns1",strategy="docker"} 0
@@ -187,6 +192,7 @@ func TestBuildCollector(t *testing.T) {
 		openshift_build_completed_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 0
         openshift_build_created_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.5e+09
         openshift_build_labels{build="build1",buildconfig="build",label_app="example1",namespace="ns1",strategy="docker"} 1
+        openshift_build_annotations{build="build1",buildconfig="build",namespace="ns1",strategy="docker",annotation_openshift_io_build_config_name="build"} 1
         openshift_build_metadata_generation_info{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 21
         openshift_build_start_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.6e+09
         openshift_build_status_phase_total{build="build1",build_phase="cancelled",buildconfig="build",namespace="ns1",strategy="docker"} 0
@@ -232,6 +238,7 @@ func TestBuildCollector(t *testing.T) {
         openshift_build_created_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.5e+09
         openshift_build_duration_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 10
         openshift_build_labels{build="build1",buildconfig="build",label_app="example1",namespace="ns1",strategy="docker"} 1
+        openshift_build_annotations{build="build1",buildconfig="build",namespace="ns1",strategy="docker",annotation_openshift_io_build_config_name="build"} 1
         openshift_build_metadata_generation_info{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 21
         openshift_build_start_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.6e+09
         openshift_build_status_phase_total{build="build1",build_phase="cancelled",buildconfig="build",namespace="ns1",strategy="docker"} 0
@@ -277,6 +284,7 @@ func TestBuildCollector(t *testing.T) {
         openshift_build_created_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.5e+09
         openshift_build_duration_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 10
         openshift_build_labels{build="build1",buildconfig="build",label_app="example1",namespace="ns1",strategy="docker"} 1
+        openshift_build_annotations{build="build1",buildconfig="build",namespace="ns1",strategy="docker",annotation_openshift_io_build_config_name="build"} 1
         openshift_build_metadata_generation_info{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 21
         openshift_build_start_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.6e+09
         openshift_build_status_phase_total{build="build1",build_phase="cancelled",buildconfig="build",namespace="ns1",strategy="docker"} 0
@@ -322,6 +330,7 @@ func TestBuildCollector(t *testing.T) {
         openshift_build_created_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.5e+09
         openshift_build_duration_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1
         openshift_build_labels{build="build1",buildconfig="build",label_app="example1",namespace="ns1",strategy="docker"} 1
+        openshift_build_annotations{build="build1",buildconfig="build",namespace="ns1",strategy="docker",annotation_openshift_io_build_config_name="build"} 1
         openshift_build_metadata_generation_info{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 21
         openshift_build_start_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.6e+09
         openshift_build_status_phase_total{build="build1",build_phase="cancelled",buildconfig="build",namespace="ns1",strategy="docker"} 0
@@ -364,6 +373,7 @@ func TestBuildCollector(t *testing.T) {
         openshift_build_completed_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 0
         openshift_build_created_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.5e+09
         openshift_build_labels{build="build1",buildconfig="build",label_app="example1",namespace="ns1",strategy="docker"} 1
+        openshift_build_annotations{build="build1",buildconfig="build",namespace="ns1",strategy="docker",annotation_openshift_io_build_config_name="build"} 1
         openshift_build_metadata_generation_info{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 21
         openshift_build_start_timestamp_seconds{build="build1",buildconfig="build",namespace="ns1",strategy="docker"} 1.6e+09
         openshift_build_status_phase_total{build="build1",build_phase="cancelled",buildconfig="build",namespace="ns1",strategy="docker"} 1
@@ -1,14 +1,23 @@
 package collectors
 
 import (
+	"fmt"
+	"reflect"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/kube-state-metrics/pkg/collector"
 	"k8s.io/kube-state-metrics/pkg/metric"
-	"k8s.io/kube-state-metrics/pkg/metrics_store"
 	"k8s.io/kube-state-metrics/pkg/options"
 
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 
 	appsv1 "github.com/openshift/api/apps/v1"
@@ -28,12 +37,53 @@ type whiteBlackLister interface {
 // Builder helps to build collectors. It follows the builder pattern
 // (https://en.wikipedia.org/wiki/Builder_pattern).
 type Builder struct {
-	apiserver         string
-	kubeconfig        string
-	namespaces        options.NamespaceList
+	restConfig         *rest.Config
+	clients            ClientFactory
+	namespaces         options.NamespaceList
+	namespaceSelector  labels.Selector
+	namespaceDenylist  []string
+	namespaceSelection *namespaceSelection
+	// namespaceKey identifies the namespace configuration the selection was
+	// created from.
+	namespaceKey      string
+	cancelSelection   context.CancelFunc
 	ctx               context.Context
 	enabledCollectors []string
+	allowLabels       map[string][]string
+	allowAnnotations  map[string][]string
+	customResources   []CustomResource
 	whiteBlackList    whiteBlackLister
+	shard             int32
+	totalShards       int32
+	eventsByNamespace bool
+	metadataOnly      bool
+	listPageSize      int64
+	watchList         bool
+
+	// mu protects the results of the last call to Build, which are read
+	// while the next one runs. Only Build replaces them, so it reads them
+	// without the lock.
+	mu sync.RWMutex
+	// built holds the collectors built by the last call to Build by name.
+	built          map[string]*builtCollector
+	namespaced     []NamespacedCollector
+	collectorNames []string
+}
+
+// builtCollector is a collector built by the Builder along with what it takes
+// to stop it again.
+type builtCollector struct {
+	collector *collector.Collector
+	// fingerprint changes whenever the collector needs to be rebuilt.
+	fingerprint string
+	scope       collectorScope
+	reflectors  *collectorReflectors
+	health      *collectorHealth
+	namespaced  *NamespacedCollector
+	cancel      context.CancelFunc
+	// labelNames are the label names of the families with fixed labels,
+	// see collectorSpec.
+	labelNames map[string][]string
 }
 
 // NewBuilder returns a new builder.
@@ -41,17 +91,23 @@ func NewBuilder(
 	ctx context.Context,
 ) *Builder {
 	return &Builder{
-		ctx: ctx,
+		ctx:         ctx,
+		totalShards: 1,
+		built:       map[string]*builtCollector{},
 	}
 }
 
-func (b *Builder) WithApiserver(apiserver string) *Builder {
-	b.apiserver = apiserver
+// WithRestConfig sets the REST config all collector clients are created from.
+func (b *Builder) WithRestConfig(config *rest.Config) *Builder {
+	b.restConfig = config
 	return b
 }
 
-func (b *Builder) WithKubeConfig(kubeconfig string) *Builder {
-	b.kubeconfig = kubeconfig
+// WithClientFactory sets the factory the collectors get their clients from.
+// It takes precedence over the REST config and is mostly useful to inject fake
+// clientsets in tests.
+func (b *Builder) WithClientFactory(f ClientFactory) *Builder {
+	b.clients = f
 	return b
 }
 
@@ -68,12 +124,82 @@ func (b *Builder) WithEnabledCollectors(c []string) *Builder {
 	return b
 }
 
+// WithAllowLabels restricts the Kubernetes labels exposed by the labels
+// families of each collector to the given ones. "*" allows all labels.
+// Collectors missing from the map expose no labels. If the map is nil, all
+// labels are exposed. The map should be checked with ValidateAllowLabels.
+func (b *Builder) WithAllowLabels(labels map[string][]string) *Builder {
+	b.allowLabels = labels
+	return b
+}
+
+// WithAllowAnnotations sets the Kubernetes annotations exposed by the
+// annotations families of each collector. "*" allows all annotations. By
+// default no annotations are exposed. The map should be checked with
+// ValidateAllowAnnotations.
+func (b *Builder) WithAllowAnnotations(annotations map[string][]string) *Builder {
+	b.allowAnnotations = annotations
+	return b
+}
+
+// ValidateAllowLabels checks that every collector of the given labels
+// allowlist exists and has a labels family.
+func ValidateAllowLabels(labels map[string][]string) error {
+	return validateAllowList(labels, "labels", func(spec collectorSpec) string { return spec.labelsFamily })
+}
+
+// ValidateAllowAnnotations checks that every collector of the given
+// annotations allowlist exists and has an annotations family.
+func ValidateAllowAnnotations(annotations map[string][]string) error {
+	return validateAllowList(annotations, "annotations", func(spec collectorSpec) string { return spec.annotationsFamily })
+}
+
+func validateAllowList(allowList map[string][]string, kind string, family func(collectorSpec) string) error {
+	names := make([]string, 0, len(allowList))
+	for name := range allowList {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		spec, ok := lookupCollector(name)
+		if !ok {
+			return fmt.Errorf("%s allowlist: collector %s does not exist", kind, name)
+		}
+		if family(spec) == "" {
+			return fmt.Errorf("%s allowlist: collector %s has no %s metric", kind, name, kind)
+		}
+	}
+	return nil
+}
+
+// WithCustomResourceState adds a collector for every resource of the given
+// config. These collectors are always enabled.
+func (b *Builder) WithCustomResourceState(config *CustomResourceStateConfig) *Builder {
+	b.customResources = config.Resources
+	return b
+}
+
 // WithNamespaces sets the namespaces property of a Builder.
 func (b *Builder) WithNamespaces(n options.NamespaceList) *Builder {
 	b.namespaces = n
 	return b
 }
 
+// WithNamespaceSelector restricts the namespaces to the ones matching the
+// given label selector. Namespaces entering and leaving the selection are
+// picked up at runtime.
+func (b *Builder) WithNamespaceSelector(selector labels.Selector) *Builder {
+	b.namespaceSelector = selector
+	return b
+}
+
+// WithNamespaceDenylist excludes the given namespaces.
+func (b *Builder) WithNamespaceDenylist(denylist []string) *Builder {
+	b.namespaceDenylist = denylist
+	return b
+}
+
 // WithWhiteBlackList configures the white or blacklisted metrics to be exposed
 // by the collectors build by the Builder
 func (b *Builder) WithWhiteBlackList(l whiteBlackLister) *Builder {
@@ -81,151 +207,564 @@ func (b *Builder) WithWhiteBlackList(l whiteBlackLister) *Builder {
 	return b
 }
 
+// WithSharding makes the collectors built by the Builder only keep objects
+// whose UID hashes to the given shard. The collectors keep no objects if the
+// shard is not below totalShards.
+func (b *Builder) WithSharding(shard int32, totalShards int32) *Builder {
+	b.shard = shard
+	b.totalShards = totalShards
+	return b
+}
+
+// WithWatchEventsByNamespace also counts the watch events of every collector
+// per namespace.
+func (b *Builder) WithWatchEventsByNamespace(enabled bool) *Builder {
+	b.eventsByNamespace = enabled
+	return b
+}
+
+// WithMetadataOnlyWatches watches only the metadata of the objects of
+// collectors whose enabled metric families need nothing else, e.g. because the
+// others are denied by the metric blacklist.
+func (b *Builder) WithMetadataOnlyWatches(enabled bool) *Builder {
+	b.metadataOnly = enabled
+	return b
+}
+
+// WithListPageSize makes the reflectors list the objects in pages of the given
+// size instead of all at once. Paginated lists are served from etcd instead of
+// the watch cache of the apiserver. 0 disables pagination.
+func (b *Builder) WithListPageSize(size int64) *Builder {
+	b.listPageSize = size
+	return b
+}
+
+// WithWatchList makes the reflectors stream their initial objects through a
+// watch instead of listing them, if the apiserver supports it.
+func (b *Builder) WithWatchList(enabled bool) *Builder {
+	b.watchList = enabled
+	return b
+}
+
 // Build initializes and registers all enabled collectors.
+//
+// Build can be called again after changing the configuration of the Builder.
+// Collectors the change does not affect keep running along with their
+// metrics. New collectors are started, disabled ones are stopped and the
+// others are rebuilt. Namespaces added to or removed from a static namespace
+// list only start or stop the reflectors of these namespaces.
 func (b *Builder) Build() []*collector.Collector {
 	if b.whiteBlackList == nil {
 		panic("whiteBlackList should not be nil")
 	}
 
+	if b.clients == nil {
+		if b.restConfig == nil {
+			panic("either restConfig or clients should be set")
+		}
+		clients, err := NewClientFactory(b.restConfig)
+		if err != nil {
+			klog.Fatalf("cannot create clients: %v", err)
+		}
+		b.clients = clients
+	}
+
+	selectionChanged, rebuildNamespaced := b.updateNamespaceSelection()
+
+	built := map[string]*builtCollector{}
+	namespaced := []NamespacedCollector{}
 	collectors := []*collector.Collector{}
 	activeCollectorNames := []string{}
 
+	add := func(name string, spec collectorSpec) {
+		fingerprint := b.fingerprint(name, spec)
+		c, ok := b.built[name]
+		// Cluster-scoped collectors filter their metrics by the namespace
+		// selection they were built with.
+		if !ok || c.fingerprint != fingerprint ||
+			(c.scope == clusterScoped && selectionChanged) ||
+			(c.scope == namespaceScoped && rebuildNamespaced) {
+			if ok {
+				klog.Infof("collector %s is rebuilt", name)
+				b.stopCollector(c)
+			}
+			c = b.buildCollector(name, spec, fingerprint)
+		}
+
+		built[name] = c
+		if c.namespaced != nil {
+			namespaced = append(namespaced, *c.namespaced)
+		}
+		activeCollectorNames = append(activeCollectorNames, name)
+		collectors = append(collectors, c.collector)
+	}
+
 	for _, c := range b.enabledCollectors {
-		constructor, ok := availableCollectors[c]
+		spec, ok := lookupCollector(c)
 		if !ok {
 			klog.Fatalf("collector %s is not correct", c)
 		}
+		add(c, spec)
+	}
 
-		collector := constructor(b)
-		activeCollectorNames = append(activeCollectorNames, c)
-		collectors = append(collectors, collector)
-
+	for _, r := range b.customResources {
+		add(r.collectorName(), r.collectorSpec())
 	}
 
+	for name, c := range b.built {
+		if _, ok := built[name]; ok {
+			continue
+		}
+		klog.Infof("collector %s is stopped", name)
+		b.stopCollector(c)
+		deleteCollectorMetrics(name)
+	}
 	klog.Infof("Active collectors: %s", strings.Join(activeCollectorNames, ","))
 
-	return collectors
-}
+	b.mu.Lock()
+	b.built = built
+	b.namespaced = namespaced
+	b.collectorNames = activeCollectorNames
+	b.mu.Unlock()
 
-var availableCollectors = map[string]func(f *Builder) *collector.Collector{
-	"deploymentConfigs":     func(b *Builder) *collector.Collector { return b.buildDeploymentCollector() },
-	"buildconfigs":          func(b *Builder) *collector.Collector { return b.buildBuildConfigCollector() },
-	"builds":                func(b *Builder) *collector.Collector { return b.buildBuildCollector() },
-	"clusterresourcequotas": func(b *Builder) *collector.Collector { return b.buildQuotaCollector() },
-	"routes":                func(b *Builder) *collector.Collector { return b.buildRouteCollector() },
-	"groups":                func(b *Builder) *collector.Collector { return b.buildGroupCollector() },
+	return collectors
 }
 
-func (b *Builder) buildRouteCollector() *collector.Collector {
-	filteredMetricFamilies := metric.FilterMetricFamilies(b.whiteBlackList, routeMetricFamilies)
-	composedMetricGenFuncs := metric.ComposeMetricGenFuncs(filteredMetricFamilies)
+// updateNamespaceSelection creates a new namespace selection if the
+// namespace configuration changed since the last call to Build. If the
+// previous and the new selection are both static lists of namespaces, the
+// namespaced collectors are moved over to the new selection. Otherwise they
+// need to be rebuilt, which is returned as rebuildNamespaced.
+func (b *Builder) updateNamespaceSelection() (changed bool, rebuildNamespaced bool) {
+	selector := ""
+	if b.namespaceSelector != nil {
+		selector = b.namespaceSelector.String()
+	}
+	namespaces := append([]string{}, b.namespaces...)
+	sort.Strings(namespaces)
+	denylist := append([]string{}, b.namespaceDenylist...)
+	sort.Strings(denylist)
+	key := fmt.Sprintf("%q %q %q", namespaces, denylist, selector)
+
+	previous := b.namespaceSelection
+	if previous != nil && key == b.namespaceKey {
+		return false, false
+	}
 
-	familyHeaders := metric.ExtractMetricFamilyHeaders(filteredMetricFamilies)
+	s := newNamespaceSelection(b.namespaces, b.namespaceDenylist, b.namespaceSelector)
+	b.namespaceSelection, b.namespaceKey = s, key
+	if previous != nil && previous.static() && s.static() {
+		s.takeOver(previous)
+		return true, false
+	}
 
-	store := metricsstore.NewMetricsStore(
-		familyHeaders,
-		composedMetricGenFuncs,
-	)
-	reflectorPerNamespace(b.ctx, &routev1.Route{}, store,
-		b.apiserver, b.kubeconfig, b.namespaces, createRouteListWatch)
+	if b.cancelSelection != nil {
+		b.cancelSelection()
+		b.cancelSelection = nil
+	}
+	if s.dynamic() {
+		ctx, cancel := context.WithCancel(b.ctx)
+		b.cancelSelection = cancel
+		s.watch(ctx, b.clients.KubeClient())
+	}
+	return true, previous != nil
+}
 
-	return collector.NewCollector(store)
+// fingerprint describes everything the collector with the given name is built
+// from, except for the namespace selection.
+func (b *Builder) fingerprint(name string, spec collectorSpec) string {
+	families := metric.FilterMetricFamilies(b.whiteBlackList, spec.families)
+	names := make([]string, len(families))
+	for i, f := range families {
+		names[i] = f.Name
+	}
+	return fmt.Sprintf("%q %t %q %q %d/%d %t %t %d %t", names, b.allowLabels == nil, b.allowLabels[name], b.allowAnnotations[name], b.shard, b.totalShards, b.eventsByNamespace, b.metadataOnly, b.listPageSize, b.watchList)
 }
 
-func (b *Builder) buildDeploymentCollector() *collector.Collector {
-	filteredMetricFamilies := metric.FilterMetricFamilies(b.whiteBlackList, deploymentMetricFamilies)
-	composedMetricGenFuncs := metric.ComposeMetricGenFuncs(filteredMetricFamilies)
+// stopCollector stops the reflectors of the given collector and removes them
+// from the namespace selection.
+func (b *Builder) stopCollector(c *builtCollector) {
+	c.cancel()
+	c.reflectors.stop()
+	b.namespaceSelection.unregister(c.reflectors)
+}
 
-	familyHeaders := metric.ExtractMetricFamilyHeaders(filteredMetricFamilies)
+// Stop stops the reflectors of all collectors built by Build and waits until
+// they are done. The metrics stay in the stores. It does not stop the
+// watches of namespaces and API groups, they end with the context of the
+// Builder.
+func (b *Builder) Stop() {
+	for _, c := range b.built {
+		c.reflectors.stop()
+	}
+}
 
-	store := metricsstore.NewMetricsStore(
-		familyHeaders,
-		composedMetricGenFuncs,
-	)
-	reflectorPerNamespace(b.ctx, &appsv1.DeploymentConfig{}, store,
-		b.apiserver, b.kubeconfig, b.namespaces, createDeploymentListWatch)
+// CollectorNames returns the names of the collectors built by the last call
+// to Build, in the same order.
+func (b *Builder) CollectorNames() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.collectorNames
+}
 
-	return collector.NewCollector(store)
+// NamespacedCollector is a collector of namespaced objects.
+type NamespacedCollector struct {
+	Name     string
+	Resource schema.GroupVersionResource
+	Store    *MetricsStore
 }
 
-func (b *Builder) buildBuildConfigCollector() *collector.Collector {
-	filteredMetricFamilies := metric.FilterMetricFamilies(b.whiteBlackList, buildconfigMetricFamilies)
-	composedMetricGenFuncs := metric.ComposeMetricGenFuncs(filteredMetricFamilies)
+// NamespacedCollectors returns the collectors of namespaced objects built by
+// the last call to Build, so that their metrics can be served per namespace.
+func (b *Builder) NamespacedCollectors() []NamespacedCollector {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.namespaced
+}
 
-	familyHeaders := metric.ExtractMetricFamilyHeaders(filteredMetricFamilies)
+// CollectorDetails describes a collector built by the Builder along with the
+// objects it holds.
+type CollectorDetails struct {
+	CollectorStatus
+	// Namespaces are the namespaces the collector watches. A single empty
+	// namespace stands for all namespaces.
+	Namespaces []string `json:"namespaces"`
+	// Objects is the number of objects in the store of the collector.
+	Objects int `json:"objects"`
+	// Series is the number of series generated for these objects.
+	Series int `json:"series"`
+	// LastEvent is the time an object was last added, updated or deleted by
+	// a watch event.
+	LastEvent time.Time `json:"lastEvent,omitempty"`
+}
 
-	store := metricsstore.NewMetricsStore(
-		familyHeaders,
-		composedMetricGenFuncs,
-	)
-	reflectorPerNamespace(b.ctx, &buildv1.BuildConfig{}, store,
-		b.apiserver, b.kubeconfig, b.namespaces, createBuildConfigListWatch)
+// Details returns the details of all collectors built by the last call to
+// Build, sorted by name. Counting the series walks the whole store of every
+// collector.
+func (b *Builder) Details() []CollectorDetails {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	details := make([]CollectorDetails, 0, len(b.built))
+	for name, c := range b.built {
+		d := CollectorDetails{
+			CollectorStatus: c.health.status(name),
+			Namespaces:      c.reflectors.namespaceList(),
+		}
+		d.Objects, d.Series, d.LastEvent = c.reflectors.store.stats()
+		details = append(details, d)
+	}
+	sort.Slice(details, func(i, j int) bool { return details[i].Name < details[j].Name })
 
-	return collector.NewCollector(store)
+	return details
 }
 
-func (b *Builder) buildBuildCollector() *collector.Collector {
-	filteredMetricFamilies := metric.FilterMetricFamilies(b.whiteBlackList, buildMetricFamilies)
-	composedMetricGenFuncs := metric.ComposeMetricGenFuncs(filteredMetricFamilies)
+// collectorScope tells whether the objects watched by a collector live in a
+// namespace or are cluster-scoped.
+type collectorScope int
 
-	familyHeaders := metric.ExtractMetricFamilyHeaders(filteredMetricFamilies)
+const (
+	namespaceScoped collectorScope = iota
+	clusterScoped
+)
 
-	store := metricsstore.NewMetricsStore(
-		familyHeaders,
-		composedMetricGenFuncs,
-	)
-	reflectorPerNamespace(b.ctx, &buildv1.Build{}, store,
-		b.apiserver, b.kubeconfig, b.namespaces, createBuildListWatch)
+// collectorSpec describes how to build a collector: which metric families it
+// generates, from which objects and how to list and watch them.
+type collectorSpec struct {
+	resource      schema.GroupVersionResource
+	families      []metric.FamilyGenerator
+	expectedType  interface{}
+	scope         collectorScope
+	listWatchFunc func(clients ClientFactory, ns string) cache.ListWatch
+	// labelsFamily and annotationsFamily name the families which convert
+	// Kubernetes labels and annotations to Prometheus labels, if any.
+	labelsFamily      string
+	annotationsFamily string
+	// metadataFamilies name the families which only read the ObjectMeta
+	// of the objects. If no other family is enabled, only the metadata of
+	// the objects can be watched.
+	metadataFamilies []string
+	// labelNames holds the label names of the families whose labels do not
+	// depend on the objects. Prometheus registries get descriptors with
+	// these labels, the other families are described by name only.
+	labelNames map[string][]string
+}
 
-	return collector.NewCollector(store)
+// availableCollectors are the collectors which can be enabled by name. It
+// holds the built-in collectors and the ones added with RegisterCollector.
+var availableCollectors = map[string]collectorSpec{
+	"deploymentConfigs": {
+		resource:          appsv1.GroupVersion.WithResource("deploymentconfigs"),
+		families:          deploymentMetricFamilies,
+		expectedType:      &appsv1.DeploymentConfig{},
+		scope:             namespaceScoped,
+		listWatchFunc:     createDeploymentListWatch,
+		labelsFamily:      descDeploymentLabelsName,
+		annotationsFamily: descDeploymentAnnotationsName,
+		metadataFamilies: []string{
+			"openshift_deploymentconfig_created",
+			"openshift_deploymentconfig_metadata_generation",
+			descDeploymentLabelsName,
+			descDeploymentAnnotationsName,
+		},
+		labelNames: familyLabelNames(deploymentMetricFamilies, descDeploymentLabelsDefaultLabels, nil, descDeploymentLabelsName, descDeploymentAnnotationsName),
+	},
+	"buildconfigs": {
+		resource:          buildv1.GroupVersion.WithResource("buildconfigs"),
+		families:          buildconfigMetricFamilies,
+		expectedType:      &buildv1.BuildConfig{},
+		scope:             namespaceScoped,
+		listWatchFunc:     createBuildConfigListWatch,
+		labelsFamily:      descBuildConfigLabelsName,
+		annotationsFamily: descBuildConfigAnnotationsName,
+		metadataFamilies: []string{
+			"openshift_buildconfig_created",
+			"openshift_buildconfig_metadata_generation",
+			descBuildConfigLabelsName,
+			descBuildConfigAnnotationsName,
+		},
+		labelNames: familyLabelNames(buildconfigMetricFamilies, descBuildConfigLabelsDefaultLabels, nil, descBuildConfigLabelsName, descBuildConfigAnnotationsName),
+	},
+	"builds": {
+		resource:          buildv1.GroupVersion.WithResource("builds"),
+		families:          buildMetricFamilies,
+		expectedType:      &buildv1.Build{},
+		scope:             namespaceScoped,
+		listWatchFunc:     createBuildListWatch,
+		labelsFamily:      descBuildLabelsName,
+		annotationsFamily: descBuildAnnotationsName,
+		// Every build metric has the strategy of the build as a label,
+		// so builds have no metadata families.
+		labelNames: familyLabelNames(buildMetricFamilies, descBuildLabelsDefaultLabels, map[string][]string{
+			"openshift_build_status_phase_total": {"build_phase"},
+		}, descBuildLabelsName, descBuildAnnotationsName),
+	},
+	"clusterresourcequotas": {
+		resource:          quotav1.GroupVersion.WithResource("clusterresourcequotas"),
+		families:          quotaMetricFamilies,
+		expectedType:      &quotav1.ClusterResourceQuota{},
+		scope:             clusterScoped,
+		listWatchFunc:     createClusterResourceQuotaListWatch,
+		labelsFamily:      descClusterResourceQuotaLabelsName,
+		annotationsFamily: descClusterResourceQuotaAnnotationsName,
+		metadataFamilies: []string{
+			"openshift_clusterresourcequota_created",
+			descClusterResourceQuotaLabelsName,
+			descClusterResourceQuotaAnnotationsName,
+		},
+		labelNames: familyLabelNames(quotaMetricFamilies, descClusterResourceQuotaLabelsDefaultLabels, map[string][]string{
+			"openshift_clusterresourcequota_usage":           {"resource", "type"},
+			"openshift_clusterresourcequota_namespace_usage": {"namespace", "resource", "type"},
+		}, descClusterResourceQuotaLabelsName, descClusterResourceQuotaAnnotationsName, "openshift_clusterresourcequota_selector"),
+	},
+	"routes": {
+		resource:          routev1.GroupVersion.WithResource("routes"),
+		families:          routeMetricFamilies,
+		expectedType:      &routev1.Route{},
+		scope:             namespaceScoped,
+		listWatchFunc:     createRouteListWatch,
+		labelsFamily:      descRouteLabelsName,
+		annotationsFamily: descRouteAnnotationsName,
+		metadataFamilies: []string{
+			"openshift_route_created",
+			descRouteLabelsName,
+			descRouteAnnotationsName,
+		},
+		labelNames: familyLabelNames(routeMetricFamilies, descRouteLabelsDefaultLabels, map[string][]string{
+			"openshift_route_info":   {"host", "path", "tls_termination", "to_kind", "to_name", "to_weight"},
+			"openshift_route_status": {"status", "type", "host", "router_name"},
+		}, descRouteLabelsName, descRouteAnnotationsName),
+	},
+	"groups": {
+		resource:      userv1.GroupVersion.WithResource("groups"),
+		families:      groupMetricFamilies,
+		expectedType:  &userv1.Group{},
+		scope:         clusterScoped,
+		listWatchFunc: createGroupListWatch,
+		metadataFamilies: []string{
+			"openshift_group_created",
+		},
+		labelNames: familyLabelNames(groupMetricFamilies, descGroupLabelsDefaultLabels, map[string][]string{
+			"openshift_group_user_account": {"user"},
+		}),
+	},
 }
 
-func (b *Builder) buildQuotaCollector() *collector.Collector {
-	filteredMetricFamilies := metric.FilterMetricFamilies(b.whiteBlackList, quotaMetricFamilies)
+// familyLabelNames returns the label names of the given families, which all
+// start with the default labels, followed by their extra labels. The labels of
+// the dynamic families depend on the objects, they are left out.
+func familyLabelNames(families []metric.FamilyGenerator, defaultLabels []string, extra map[string][]string, dynamic ...string) map[string][]string {
+	labelNames := make(map[string][]string, len(families))
+	for _, f := range families {
+		labelNames[f.Name] = append(defaultLabels[:len(defaultLabels):len(defaultLabels)], extra[f.Name]...)
+	}
+	for _, name := range dynamic {
+		delete(labelNames, name)
+	}
+	return labelNames
+}
+
+// metricFamilies returns the metric families of a collector which are
+// allowed by the metric lists and the labels and annotations allowlists.
+func (b *Builder) metricFamilies(name string, spec collectorSpec) []metric.FamilyGenerator {
+	filteredMetricFamilies := metric.FilterMetricFamilies(b.whiteBlackList, spec.families)
+	if spec.labelsFamily != "" && b.allowLabels != nil {
+		filteredMetricFamilies = filterKubeLabels(filteredMetricFamilies, spec.labelsFamily, "label_", b.allowLabels[name])
+	}
+	if spec.annotationsFamily != "" {
+		filteredMetricFamilies = filterKubeLabels(filteredMetricFamilies, spec.annotationsFamily, "annotation_", b.allowAnnotations[name])
+	}
+	return filteredMetricFamilies
+}
+
+func (b *Builder) buildCollector(name string, spec collectorSpec, fingerprint string) *builtCollector {
+	filteredMetricFamilies := b.metricFamilies(name, spec)
 	composedMetricGenFuncs := metric.ComposeMetricGenFuncs(filteredMetricFamilies)
 
 	familyHeaders := metric.ExtractMetricFamilyHeaders(filteredMetricFamilies)
 
-	store := metricsstore.NewMetricsStore(
+	store := NewMetricsStore(
 		familyHeaders,
 		composedMetricGenFuncs,
 	)
-	reflectorPerNamespace(b.ctx, &quotav1.ClusterResourceQuota{}, store,
-		b.apiserver, b.kubeconfig, b.namespaces, createClusterResourceQuotaListWatch)
+	store.objects = StoreObjectsMetric.WithLabelValues(name)
+	store.objects.Set(0)
+	if spec.scope == clusterScoped && b.namespaceSelection.restricted() {
+		// Cluster-scoped objects can still carry per-namespace data, like the
+		// namespaces a ClusterResourceQuota applies to. Only keep the
+		// selected namespaces there.
+		store.namespaceLabels = b.namespaceSelection.has
+	}
 
-	return collector.NewCollector(store)
+	// The reflectors run while the Builder is reconfigured, so they must
+	// not read its fields.
+	clients, selection := b.clients, b.namespaceSelection
+	denied := spec.scope == namespaceScoped && len(b.namespaceDenylist) > 0
+	paginate := b.listPageSize > 0
+	listWatchFunc, expectedType := spec.listWatchFunc, spec.expectedType
+	metadataOnly := b.metadataOnly && len(spec.metadataFamilies) > 0 && onlyMetadataFamilies(filteredMetricFamilies, spec.metadataFamilies)
+	if metadataOnly {
+		klog.Infof("Watching only the metadata of %s", spec.resource.Resource)
+		listWatchFunc = func(clients ClientFactory, ns string) cache.ListWatch {
+			return createMetadataListWatch(clients, spec.resource, ns)
+		}
+		expectedType = &metav1.PartialObjectMetadata{}
+	}
+	listWatch := func(ns string) cache.ListWatch {
+		lw := listWatchFunc(clients, ns)
+		if denied && ns == metav1.NamespaceAll {
+			lw = withFieldSelector(lw, selection.deniedFieldSelector())
+		}
+		if paginate {
+			lw = withPagination(lw)
+		}
+		return lw
+	}
+	ctx, cancel := context.WithCancel(b.ctx)
+	health := newCollectorHealth(name)
+	reflectors := newCollectorReflectors(ctx, expectedType, listWatch, store, health)
+	reflectors.eventsByNamespace = b.eventsByNamespace
+	reflectors.listPageSize = b.listPageSize
+	reflectors.watchList = b.watchList
+	if metadataOnly {
+		objectType := reflect.TypeOf(spec.expectedType).Elem()
+		reflectors.wrapStore = func(s cache.Store) cache.Store {
+			return &metadataStore{Store: s, objectType: objectType}
+		}
+	}
+	if b.totalShards != 1 || b.shard != 0 {
+		shard, totalShards := uint64(b.shard), uint64(b.totalShards)
+		wrapStore := reflectors.wrapStore
+		reflectors.wrapStore = func(s cache.Store) cache.Store {
+			return &shardedStore{Store: wrapStore(s), shard: shard, totalShards: totalShards}
+		}
+	}
+	selection.register(reflectors, spec.scope)
+
+	c := &builtCollector{
+		collector:   collector.NewCollector(store),
+		fingerprint: fingerprint,
+		scope:       spec.scope,
+		reflectors:  reflectors,
+		health:      health,
+		cancel:      cancel,
+		labelNames:  spec.labelNames,
+	}
+	if spec.scope == namespaceScoped {
+		c.namespaced = &NamespacedCollector{Name: name, Resource: spec.resource, Store: store}
+	}
+
+	// The store is returned right away, so that the collector is exposed
+	// even if its API is not served yet. The reflectors are started once it
+	// is.
+	startWhenServed(ctx, clients.Discovery(), name, spec.resource, health, reflectors.start)
+
+	return c
 }
 
-func (b *Builder) buildGroupCollector() *collector.Collector {
-	filteredMetricFamilies := metric.FilterMetricFamilies(b.whiteBlackList, groupMetricFamilies)
-	composedMetricGenFuncs := metric.ComposeMetricGenFuncs(filteredMetricFamilies)
+// filterKubeLabels wraps the metric family with the given name, so that it only
+// keeps the Prometheus labels converted from the allowed Kubernetes labels or
+// annotations. These are the labels starting with prefix.
+func filterKubeLabels(families []metric.FamilyGenerator, name string, prefix string, allowed []string) []metric.FamilyGenerator {
+	allowedKeys := map[string]struct{}{}
+	for _, a := range allowed {
+		if a == "*" {
+			return families
+		}
+		allowedKeys[prefix+sanitizeLabelName(a)] = struct{}{}
+	}
 
-	familyHeaders := metric.ExtractMetricFamilyHeaders(filteredMetricFamilies)
+	filtered := make([]metric.FamilyGenerator, len(families))
+	for i, f := range families {
+		if f.Name == name {
+			generateFunc := f.GenerateFunc
+			f.GenerateFunc = func(obj interface{}) metric.Family {
+				family := generateFunc(obj)
+				for _, m := range family.Metrics {
+					keys, values := make([]string, 0, len(m.LabelKeys)), make([]string, 0, len(m.LabelValues))
+					for j, k := range m.LabelKeys {
+						if _, ok := allowedKeys[k]; ok || !strings.HasPrefix(k, prefix) {
+							keys, values = append(keys, k), append(values, m.LabelValues[j])
+						}
+					}
+					m.LabelKeys, m.LabelValues = keys, values
+				}
+				return family
+			}
+		}
+		filtered[i] = f
+	}
 
-	store := metricsstore.NewMetricsStore(
-		familyHeaders,
-		composedMetricGenFuncs,
-	)
-	reflectorPerNamespace(b.ctx, &userv1.Group{}, store,
-		b.apiserver, b.kubeconfig, b.namespaces, createGroupListWatch)
+	return filtered
+}
 
-	return collector.NewCollector(store)
+// withPagination wraps the given ListWatch, so that lists with a limit are
+// read from etcd. The watch cache of the apiserver ignores the limit of the
+// lists at resourceVersion 0 the reflectors start with and would return all
+// objects at once.
+func withPagination(lw cache.ListWatch) cache.ListWatch {
+	list := lw.ListFunc
+	lw.ListFunc = func(opts metav1.ListOptions) (runtime.Object, error) {
+		if opts.Limit > 0 && opts.ResourceVersion == "0" {
+			opts.ResourceVersion = ""
+			opts.ResourceVersionMatch = ""
+		}
+		return list(opts)
+	}
+	return lw
 }
 
-// reflectorPerNamespace creates a Kubernetes client-go reflector with the given
-// listWatchFunc for each given namespace and registers it with the given store.
-func reflectorPerNamespace(
-	ctx context.Context,
-	expectedType interface{},
-	store cache.Store,
-	apiserver string,
-	kubeconfig string,
-	namespaces []string,
-	listWatchFunc func(apiserver string, kubeconfig string, ns string) cache.ListWatch,
-) {
-	for _, ns := range namespaces {
-		lw := listWatchFunc(apiserver, kubeconfig, ns)
-		reflector := cache.NewReflector(&lw, expectedType, store, 0)
-		go reflector.Run(ctx.Done())
+// withFieldSelector wraps the given ListWatch, so that it only lists and
+// watches objects matching the given field selector.
+func withFieldSelector(lw cache.ListWatch, selector string) cache.ListWatch {
+	list, watchFunc := lw.ListFunc, lw.WatchFunc
+	lw.ListFunc = func(opts metav1.ListOptions) (runtime.Object, error) {
+		opts.FieldSelector = selector
+		return list(opts)
+	}
+	lw.WatchFunc = func(opts metav1.ListOptions) (watch.Interface, error) {
+		opts.FieldSelector = selector
+		return watchFunc(opts)
 	}
+	return lw
 }
@@ -0,0 +1,865 @@
+package collectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/metadata"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	"k8s.io/client-go/rest"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/kube-state-metrics/pkg/collector"
+	"k8s.io/kube-state-metrics/pkg/options"
+	"k8s.io/kube-state-metrics/pkg/whiteblacklist"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	quotav1 "github.com/openshift/api/quota/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	userv1 "github.com/openshift/api/user/v1"
+	appsclient "github.com/openshift/client-go/apps/clientset/versioned"
+	appsfake "github.com/openshift/client-go/apps/clientset/versioned/fake"
+	buildclient "github.com/openshift/client-go/build/clientset/versioned"
+	buildfake "github.com/openshift/client-go/build/clientset/versioned/fake"
+	quotaclient "github.com/openshift/client-go/quota/clientset/versioned"
+	quotafake "github.com/openshift/client-go/quota/clientset/versioned/fake"
+	routeclient "github.com/openshift/client-go/route/clientset/versioned"
+	routefake "github.com/openshift/client-go/route/clientset/versioned/fake"
+	userclient "github.com/openshift/client-go/user/clientset/versioned"
+	userfake "github.com/openshift/client-go/user/clientset/versioned/fake"
+)
+
+// fakeDiscovery serves the resources of all built-in collectors unless they
+// are removed from served.
+type fakeDiscovery struct {
+	*fakediscovery.FakeDiscovery
+
+	mu     sync.Mutex
+	served map[schema.GroupVersionResource]bool
+	// err fails every discovery if set.
+	err error
+}
+
+func newFakeDiscovery() *fakeDiscovery {
+	d := &fakeDiscovery{
+		FakeDiscovery: &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}},
+		served:        map[schema.GroupVersionResource]bool{},
+	}
+	for _, spec := range availableCollectors {
+		d.served[spec.resource] = true
+	}
+	return d
+}
+
+func (d *fakeDiscovery) setServed(gvr schema.GroupVersionResource, served bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.served[gvr] = served
+}
+
+func (d *fakeDiscovery) setError(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+}
+
+func (d *fakeDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	list := &metav1.APIResourceList{GroupVersion: groupVersion}
+	for gvr, served := range d.served {
+		if served && gvr.GroupVersion().String() == groupVersion {
+			list.APIResources = append(list.APIResources, metav1.APIResource{Name: gvr.Resource})
+		}
+	}
+	if len(list.APIResources) == 0 {
+		gv, _ := schema.ParseGroupVersion(groupVersion)
+		return nil, apierrors.NewNotFound(gv.WithResource("").GroupResource(), "")
+	}
+	return list, nil
+}
+
+type fakeClientFactory struct {
+	discovery *fakeDiscovery
+	kube      *kubefake.Clientset
+	dynamic   *dynamicfake.FakeDynamicClient
+	metadata  *metadatafake.FakeMetadataClient
+	apps      *appsfake.Clientset
+	build     *buildfake.Clientset
+	quota     *quotafake.Clientset
+	route     *routefake.Clientset
+	user      *userfake.Clientset
+}
+
+func newFakeClientFactory() *fakeClientFactory {
+	return &fakeClientFactory{
+		discovery: newFakeDiscovery(),
+		kube:      kubefake.NewSimpleClientset(),
+		dynamic:   dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+		metadata:  newFakeMetadataClient(),
+		apps:      appsfake.NewSimpleClientset(),
+		build:     buildfake.NewSimpleClientset(),
+		quota:     quotafake.NewSimpleClientset(),
+		route:     routefake.NewSimpleClientset(),
+		user:      userfake.NewSimpleClientset(),
+	}
+}
+
+func (f *fakeClientFactory) Discovery() discovery.DiscoveryInterface {
+	return f.discovery
+}
+
+func (f *fakeClientFactory) KubeClient() kubernetes.Interface {
+	return f.kube
+}
+
+func (f *fakeClientFactory) DynamicClient() dynamic.Interface {
+	return f.dynamic
+}
+
+func (f *fakeClientFactory) MetadataClient() metadata.Interface {
+	return f.metadata
+}
+
+func (f *fakeClientFactory) AppsClient() appsclient.Interface   { return f.apps }
+func (f *fakeClientFactory) BuildClient() buildclient.Interface { return f.build }
+func (f *fakeClientFactory) QuotaClient() quotaclient.Interface { return f.quota }
+func (f *fakeClientFactory) RouteClient() routeclient.Interface { return f.route }
+func (f *fakeClientFactory) UserClient() userclient.Interface   { return f.user }
+
+// newFakeMetadataClient returns a fake metadata client serving the metadata
+// of the given objects, which must be PartialObjectMetadata with their
+// TypeMeta set.
+func newFakeMetadataClient(objects ...runtime.Object) *metadatafake.FakeMetadataClient {
+	scheme := runtime.NewScheme()
+	metav1.AddMetaToScheme(scheme)
+	return metadatafake.NewSimpleMetadataClient(scheme, objects...)
+}
+
+func newTestBuilder(t *testing.T, ctx context.Context, clients ClientFactory) *Builder {
+	t.Helper()
+
+	l, err := whiteblacklist.New(options.MetricSet{}, options.MetricSet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewBuilder(ctx).
+		WithClientFactory(clients).
+		WithNamespaces(options.DefaultNamespaces).
+		WithWhiteBlackList(l)
+}
+
+func collect(collectors []*collector.Collector) string {
+	buf := &bytes.Buffer{}
+	for _, c := range collectors {
+		c.Collect(buf)
+	}
+	return buf.String()
+}
+
+// waitForOutput waits until the output of the given collectors contains all
+// of the wanted lines.
+func waitForOutput(t *testing.T, collectors []*collector.Collector, want ...string) string {
+	t.Helper()
+
+	var out string
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		out = collect(collectors)
+		for _, w := range want {
+			if !strings.Contains(out, w) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+	}
+
+	return out
+}
+
+func TestBuilderUsesClientFactory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	weight := int32(100)
+	clients := newFakeClientFactory()
+	clients.route = routefake.NewSimpleClientset(&routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{Name: "route1", Namespace: "ns1", UID: "route1"},
+		Spec: routev1.RouteSpec{
+			Host: "example.com",
+			To:   routev1.RouteTargetReference{Kind: "Service", Name: "svc1", Weight: &weight},
+		},
+	})
+	clients.user = userfake.NewSimpleClientset(&userv1.Group{
+		ObjectMeta: metav1.ObjectMeta{Name: "group1", UID: "group1"},
+		Users:      []string{"user1"},
+	})
+
+	collectors := newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"routes", "groups"}).
+		Build()
+
+	waitForOutput(t, collectors,
+		`openshift_route_info{namespace="ns1",route="route1",host="example.com"`,
+		`openshift_group_user_account{group="group1",user="user1"} 1`,
+	)
+}
+
+func TestBuilderDetails(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clients := newFakeClientFactory()
+	clients.route = routefake.NewSimpleClientset(newTestRoute("ns1", "route1"))
+
+	b := newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"routes", "groups"})
+	waitForOutput(t, b.Build(), `route="route1"`)
+
+	details := b.Details()
+	if len(details) != 2 {
+		t.Fatalf("expected details of 2 collectors, got %+v", details)
+	}
+	if d := details[1]; d.Name != "routes" || d.Objects != 1 || d.Series == 0 || len(d.Namespaces) != 1 || d.Namespaces[0] != metav1.NamespaceAll {
+		t.Errorf("expected routes to hold 1 object of all namespaces, got %+v", d)
+	}
+}
+
+func TestBuilderObject(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clients := newFakeClientFactory()
+	clients.route = routefake.NewSimpleClientset(newTestRoute("ns1", "route1"))
+
+	b := newTestBuilder(t, ctx, clients).WithEnabledCollectors([]string{"routes"})
+	waitForOutput(t, b.Build(), `route="route1"`)
+
+	if object, ok, err := b.Object("routes", "ns1", "route1"); err != nil || !ok || object.Key != "ns1/route1" {
+		t.Errorf("expected route ns1/route1 to be found, got %+v, %t, %v", object, ok, err)
+	}
+	if _, ok, err := b.Object("routes", "ns2", "route1"); err != nil || ok {
+		t.Errorf("expected route ns2/route1 not to be found, got %t, %v", ok, err)
+	}
+	if _, _, err := b.Object("builds", "ns1", "route1"); !errors.Is(err, ErrUnknownCollector) {
+		t.Errorf("expected an unknown collector error, got %v", err)
+	}
+}
+
+func TestBuilderCollectorNames(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := newTestBuilder(t, ctx, newFakeClientFactory()).
+		WithEnabledCollectors([]string{"routes", "groups"})
+	b.Build()
+
+	if names := strings.Join(b.CollectorNames(), ","); names != "groups,routes" {
+		t.Errorf("expected collectors groups,routes, got %s", names)
+	}
+	namespaced := b.NamespacedCollectors()
+	if len(namespaced) != 1 || namespaced[0].Name != "routes" || namespaced[0].Resource.Resource != "routes" {
+		t.Errorf("expected routes to be the only namespaced collector, got %+v", namespaced)
+	}
+}
+
+func TestBuilderPrometheusCollectors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clients := newFakeClientFactory()
+	clients.route = routefake.NewSimpleClientset(newTestRoute("ns1", "r1"))
+
+	b := newTestBuilder(t, ctx, clients).WithEnabledCollectors([]string{"groups", "routes"})
+	waitForOutput(t, b.Build(), `route="r1"`)
+	pc := b.PrometheusCollectors()
+	if len(pc) != 2 || testutil.CollectAndCount(pc[1], "openshift_route_info") != 1 {
+		t.Fatalf("expected the route info to be collected by the second of 2 Prometheus collectors, got %d collectors", len(pc))
+	}
+
+	// A rebuilt collector is collected from its new store.
+	infoOnly, err := whiteblacklist.New(options.MetricSet{"openshift_route_info": {}}, options.MetricSet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForOutput(t, b.WithWhiteBlackList(infoOnly).Build(), `route="r1"`)
+	if n := testutil.CollectAndCount(pc[1]); n != 1 {
+		t.Errorf("expected only the route info to be collected after the rebuild, got %d series", n)
+	}
+
+	// A disabled collector is collected as empty.
+	b.WithEnabledCollectors([]string{"groups"}).Build()
+	if n := testutil.CollectAndCount(pc[1]); n != 0 {
+		t.Errorf("expected nothing to be collected for the disabled routes, got %d series", n)
+	}
+}
+
+func TestBuilderStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clients := newFakeClientFactory()
+	clients.route = routefake.NewSimpleClientset(newTestRoute("ns1", "route1"))
+
+	b := newTestBuilder(t, ctx, clients).WithEnabledCollectors([]string{"routes"})
+	collectors := b.Build()
+	waitForOutput(t, collectors, `namespace="ns1",route="route1"`)
+
+	b.Stop()
+	if _, err := clients.route.RouteV1().Routes("ns1").Create(ctx, newTestRoute("ns1", "route2"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	out := collect(collectors)
+	if !strings.Contains(out, `route="route1"`) {
+		t.Errorf("expected the metrics to be kept after stopping, got:\n%s", out)
+	}
+	if strings.Contains(out, `route="route2"`) {
+		t.Errorf("expected no updates after stopping, got:\n%s", out)
+	}
+}
+
+func TestBuilderClusterScopedCollectors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clients := newFakeClientFactory()
+	clients.quota = quotafake.NewSimpleClientset(&quotav1.ClusterResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "quota1", UID: "quota1"},
+		Status: quotav1.ClusterResourceQuotaStatus{
+			Namespaces: quotav1.ResourceQuotasStatusByNamespace{
+				{
+					Namespace: "a",
+					Status: corev1.ResourceQuotaStatus{
+						Used: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")},
+					},
+				},
+				{
+					Namespace: "d",
+					Status: corev1.ResourceQuotaStatus{
+						Used: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("2")},
+					},
+				},
+			},
+		},
+	})
+
+	collectors := newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"clusterresourcequotas"}).
+		WithNamespaces(options.NamespaceList{"a", "b", "c"}).
+		Build()
+
+	out := waitForOutput(t, collectors,
+		`openshift_clusterresourcequota_namespace_usage{name="quota1",namespace="a",resource="pods",type="used"} 1`,
+	)
+	if strings.Contains(out, `namespace="d"`) {
+		t.Errorf("expected namespace d to be filtered out, got:\n%s", out)
+	}
+
+	lists := 0
+	for _, a := range clients.quota.Actions() {
+		if a.GetVerb() == "list" {
+			lists++
+			if ns := a.GetNamespace(); ns != metav1.NamespaceAll {
+				t.Errorf("expected cluster-wide list, got namespace %q", ns)
+			}
+		}
+	}
+	if lists != 1 {
+		t.Errorf("expected exactly one reflector to list quotas, got %d lists", lists)
+	}
+}
+
+func TestBuilderWaitsForServedAPI(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interval := apiDiscoveryInterval
+	apiDiscoveryInterval = 10 * time.Millisecond
+	defer func() { apiDiscoveryInterval = interval }()
+
+	clients := newFakeClientFactory()
+	clients.build = buildfake.NewSimpleClientset(&buildv1.BuildConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "bc1", Namespace: "ns1", UID: "bc1"},
+	})
+	gvr := availableCollectors["buildconfigs"].resource
+	clients.discovery.setServed(gvr, false)
+
+	collectors := newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"buildconfigs"}).
+		Build()
+
+	if got := testutil.ToFloat64(CollectorEnabledMetric.WithLabelValues("buildconfigs", reasonAPINotServed)); got != 0 {
+		t.Errorf("expected buildconfigs collector to be disabled, got %v", got)
+	}
+	if n := len(clients.build.Actions()); n != 0 {
+		t.Errorf("expected no requests while the API is not served, got %d", n)
+	}
+
+	clients.discovery.setServed(gvr, true)
+
+	waitForOutput(t, collectors, `openshift_buildconfig_created{namespace="ns1",buildconfig="bc1"}`)
+	if got := testutil.ToFloat64(CollectorEnabledMetric.WithLabelValues("buildconfigs", reasonAPIServed)); got != 1 {
+		t.Errorf("expected buildconfigs collector to be enabled, got %v", got)
+	}
+}
+
+func TestBuilderRetriesFailedDiscovery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Only failed discoveries are retried before the interval is over.
+	interval, retryInterval := apiDiscoveryInterval, discoveryRetryInterval
+	apiDiscoveryInterval, discoveryRetryInterval = time.Hour, 10*time.Millisecond
+	defer func() { apiDiscoveryInterval, discoveryRetryInterval = interval, retryInterval }()
+
+	clients := newFakeClientFactory()
+	clients.build = buildfake.NewSimpleClientset(&buildv1.BuildConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "bc1", Namespace: "ns1", UID: "bc1"},
+	})
+	clients.discovery.setError(errors.New("connection refused"))
+
+	b := newTestBuilder(t, ctx, clients).WithEnabledCollectors([]string{"buildconfigs"})
+	collectors := b.Build()
+
+	if got := testutil.ToFloat64(CollectorEnabledMetric.WithLabelValues("buildconfigs", reasonDiscoveryFailed)); got != 0 {
+		t.Errorf("expected buildconfigs collector to be disabled, got %v", got)
+	}
+	if s := b.Status()[0]; s.Started || !s.DiscoveryFailed {
+		t.Errorf("expected the collector to wait for a failed discovery, got %+v", s)
+	}
+
+	clients.discovery.setError(nil)
+
+	waitForOutput(t, collectors, `openshift_buildconfig_created{namespace="ns1",buildconfig="bc1"}`)
+	if s := b.Status()[0]; !s.Started || s.DiscoveryFailed {
+		t.Errorf("expected the collector to be started after the discovery succeeded, got %+v", s)
+	}
+}
+
+func TestBuilderAllowLabelsAndAnnotations(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	route := newTestRoute("ns1", "r1")
+	route.Labels = map[string]string{"team": "a", "openshift.io/build.start-policy": "Serial"}
+	route.Annotations = map[string]string{"example.com/owner": "team-a", "example.com/commit": "0123abc"}
+	build := &buildv1.Build{ObjectMeta: metav1.ObjectMeta{
+		Name:      "b1",
+		Namespace: "ns1",
+		UID:       "b1",
+		Labels:    map[string]string{"app": "a"},
+	}}
+
+	clients := newFakeClientFactory()
+	clients.route = routefake.NewSimpleClientset(route)
+	clients.build = buildfake.NewSimpleClientset(build)
+
+	collectors := newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"routes", "builds"}).
+		WithAllowLabels(map[string][]string{"routes": {"team"}}).
+		WithAllowAnnotations(map[string][]string{"routes": {"example.com/owner"}}).
+		Build()
+
+	waitForOutput(t, collectors,
+		`openshift_route_labels{namespace="ns1",route="r1",label_team="a"} 1`,
+		`openshift_route_annotations{namespace="ns1",route="r1",annotation_example_com_owner="team-a"} 1`,
+		// Collectors missing from the allowlists expose neither labels nor
+		// annotations.
+		`openshift_build_labels{namespace="ns1",build="b1",buildconfig="",strategy=""} 1`,
+		`openshift_build_annotations{namespace="ns1",build="b1",buildconfig="",strategy=""} 1`,
+	)
+}
+
+func TestValidateAllowLists(t *testing.T) {
+	if err := ValidateAllowLabels(map[string][]string{"routes": {"team"}, "builds": {"*"}}); err != nil {
+		t.Errorf("expected a valid labels allowlist, got %v", err)
+	}
+	if err := ValidateAllowAnnotations(map[string][]string{"deploymentConfigs": {"owner"}}); err != nil {
+		t.Errorf("expected a valid annotations allowlist, got %v", err)
+	}
+
+	tests := []struct {
+		validate  func(map[string][]string) error
+		allowList map[string][]string
+		want      string
+	}{
+		{ValidateAllowLabels, map[string][]string{"route": {"team"}}, "collector route does not exist"},
+		{ValidateAllowLabels, map[string][]string{"groups": {"team"}}, "collector groups has no labels metric"},
+		{ValidateAllowAnnotations, map[string][]string{"groups": {"owner"}}, "collector groups has no annotations metric"},
+	}
+	for _, test := range tests {
+		if err := test.validate(test.allowList); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%v: expected an error containing %q, got %v", test.allowList, test.want, err)
+		}
+	}
+}
+
+func TestBuilderMetadataOnlyWatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clients := newFakeClientFactory()
+	clients.route = routefake.NewSimpleClientset(newTestRoute("ns1", "r1"))
+	clients.metadata = newFakeMetadataClient(&metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: routev1.GroupVersion.String(), Kind: "Route"},
+		ObjectMeta: metav1.ObjectMeta{Name: "r1", Namespace: "ns1", UID: "ns1/r1", Labels: map[string]string{"team": "a"}},
+	})
+
+	labelsOnly, err := whiteblacklist.New(options.MetricSet{"openshift_route_labels": {}}, options.MetricSet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"routes"}).
+		WithWhiteBlackList(labelsOnly).
+		WithMetadataOnlyWatches(true)
+	waitForOutput(t, b.Build(), `openshift_route_labels{namespace="ns1",route="r1",label_team="a"} 1`)
+	if actions := clients.route.Actions(); len(actions) != 0 {
+		t.Errorf("expected only the metadata of routes to be watched, got route client actions %v", actions)
+	}
+
+	// Families reading the spec need the full objects again.
+	withInfo, err := whiteblacklist.New(options.MetricSet{"openshift_route_labels": {}, "openshift_route_info": {}}, options.MetricSet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForOutput(t, b.WithWhiteBlackList(withInfo).Build(), `openshift_route_info{namespace="ns1",route="r1"`)
+}
+
+// routeServerClients serves the routes of the given pages through a real
+// route client, so that the options of the list requests can be checked. Each
+// page but the last continues with the next one.
+type routeServerClients struct {
+	*fakeClientFactory
+	routes routeclient.Interface
+}
+
+func (f *routeServerClients) RouteClient() routeclient.Interface { return f.routes }
+
+func TestBuilderListPageSize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pages := [][]routev1.Route{
+		{*newTestRoute("ns1", "r1"), *newTestRoute("ns1", "r2")},
+		{*newTestRoute("ns2", "r3")},
+	}
+	var (
+		mu    sync.Mutex
+		lists []url.Values
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		if query.Get("watch") == "true" {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		mu.Lock()
+		lists = append(lists, query)
+		mu.Unlock()
+
+		page := 0
+		if c := query.Get("continue"); c != "" {
+			page, _ = strconv.Atoi(c)
+		}
+		list := &routev1.RouteList{
+			TypeMeta: metav1.TypeMeta{APIVersion: routev1.GroupVersion.String(), Kind: "RouteList"},
+			ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+			Items:    pages[page],
+		}
+		if page+1 < len(pages) {
+			list.Continue = strconv.Itoa(page + 1)
+		}
+		json.NewEncoder(w).Encode(list)
+	}))
+	defer func() {
+		// Stop the reflectors first, the server waits for their watches.
+		cancel()
+		server.Close()
+	}()
+
+	routes, err := routeclient.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := &routeServerClients{fakeClientFactory: newFakeClientFactory(), routes: routes}
+	b := newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"routes"}).
+		WithListPageSize(2)
+	waitForOutput(t, b.Build(), `route="r1"`, `route="r2"`, `route="r3"`)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lists) != len(pages) {
+		t.Fatalf("expected %d list requests, got %v", len(pages), lists)
+	}
+	for i, query := range lists {
+		if limit := query.Get("limit"); limit != "2" {
+			t.Errorf("expected list %d to ask for 2 objects, got limit %q", i, limit)
+		}
+		// Lists at resourceVersion 0 would be served from the watch cache,
+		// which ignores the limit.
+		if rv := query.Get("resourceVersion"); rv != "" {
+			t.Errorf("expected list %d to be read from etcd, got resourceVersion %q", i, rv)
+		}
+		if want := map[int]string{0: "", 1: "1"}[i]; query.Get("continue") != want {
+			t.Errorf("expected list %d to continue %q, got %q", i, want, query.Get("continue"))
+		}
+	}
+}
+
+func TestWithPagination(t *testing.T) {
+	var got metav1.ListOptions
+	lw := withPagination(cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			got = opts
+			return &routev1.RouteList{}, nil
+		},
+	})
+
+	tests := []struct {
+		opts metav1.ListOptions
+		want metav1.ListOptions
+	}{
+		{
+			opts: metav1.ListOptions{ResourceVersion: "0", Limit: 500},
+			want: metav1.ListOptions{Limit: 500},
+		},
+		{
+			opts: metav1.ListOptions{ResourceVersion: "0", ResourceVersionMatch: metav1.ResourceVersionMatchNotOlderThan, Limit: 500},
+			want: metav1.ListOptions{Limit: 500},
+		},
+		{
+			opts: metav1.ListOptions{ResourceVersion: "0"},
+			want: metav1.ListOptions{ResourceVersion: "0"},
+		},
+		{
+			opts: metav1.ListOptions{ResourceVersion: "42", ResourceVersionMatch: metav1.ResourceVersionMatchExact, Limit: 500},
+			want: metav1.ListOptions{ResourceVersion: "42", ResourceVersionMatch: metav1.ResourceVersionMatchExact, Limit: 500},
+		},
+	}
+	for _, test := range tests {
+		if _, err := lw.List(test.opts); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("expected list %+v to be sent as %+v, got %+v", test.opts, test.want, got)
+		}
+	}
+}
+
+func TestBuilderRebuild(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clients := newFakeClientFactory()
+	clients.route = routefake.NewSimpleClientset(newTestRoute("ns1", "r1"), newTestRoute("ns2", "r2"))
+	clients.user = userfake.NewSimpleClientset(&userv1.Group{
+		ObjectMeta: metav1.ObjectMeta{Name: "group1", UID: "group1"},
+		Users:      []string{"user1"},
+	})
+
+	b := newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"groups", "routes"}).
+		WithNamespaces(options.NamespaceList{"ns1"})
+	collectors := b.Build()
+	waitForOutput(t, collectors, `route="r1"`, `group="group1"`)
+
+	routeLists := func(ns string) int {
+		n := 0
+		for _, a := range clients.route.Actions() {
+			if a.GetVerb() == "list" && a.GetNamespace() == ns {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Adding a namespace and disabling a collector keeps the routes
+	// collector and the reflector of ns1 running.
+	rebuilt := b.WithEnabledCollectors([]string{"routes"}).
+		WithNamespaces(options.NamespaceList{"ns1", "ns2"}).
+		Build()
+	if len(rebuilt) != 1 || rebuilt[0] != collectors[1] {
+		t.Fatalf("expected the routes collector to be kept, got %v", rebuilt)
+	}
+	out := waitForOutput(t, rebuilt, `route="r1"`, `route="r2"`)
+	if n := routeLists("ns1"); n != 1 {
+		t.Errorf("expected routes of ns1 to be listed once, got %d lists", n)
+	}
+	if names := strings.Join(b.CollectorNames(), ","); names != "routes" {
+		t.Errorf("expected collectors routes, got %s", names)
+	}
+	if len(b.Status()) != 1 {
+		t.Errorf("expected the status of a single collector, got %+v", b.Status())
+	}
+	if strings.Contains(out, "group1") {
+		t.Errorf("expected the groups collector to be dropped, got:\n%s", out)
+	}
+
+	// Removing a namespace drops its metrics.
+	rebuilt = b.WithNamespaces(options.NamespaceList{"ns2"}).Build()
+	if rebuilt[0] != collectors[1] {
+		t.Fatalf("expected the routes collector to be kept")
+	}
+	if out := collect(rebuilt); strings.Contains(out, `route="r1"`) {
+		t.Errorf("expected the metrics of ns1 to be dropped, got:\n%s", out)
+	}
+
+	// Switching to all namespaces rebuilds the collector.
+	rebuilt = b.WithNamespaces(options.DefaultNamespaces).Build()
+	if rebuilt[0] == collectors[1] {
+		t.Fatalf("expected the routes collector to be rebuilt")
+	}
+	waitForOutput(t, rebuilt, `route="r1"`, `route="r2"`)
+	if n := routeLists(metav1.NamespaceAll); n != 1 {
+		t.Errorf("expected routes of all namespaces to be listed once, got %d lists", n)
+	}
+}
+
+func TestBuilderSelfMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clients := newFakeClientFactory()
+	clients.route = routefake.NewSimpleClientset(newTestRoute("ns1", "r1"), newTestRoute("ns1", "r2"))
+	failures := 1
+	clients.route.PrependReactor("list", "routes", func(clienttesting.Action) (bool, runtime.Object, error) {
+		if failures > 0 {
+			failures--
+			return true, nil, apierrors.NewServiceUnavailable("unavailable")
+		}
+		return false, nil, nil
+	})
+
+	scrapeErrors := testutil.ToFloat64(ScrapeErrorTotalMetric.WithLabelValues("routes"))
+	lists := testutil.ToFloat64(ListWatchRequestsMetric.WithLabelValues("routes", "list", "success"))
+	initialLists := initialListCount(t, "routes")
+
+	b := newTestBuilder(t, ctx, clients).WithEnabledCollectors([]string{"routes"})
+	waitForOutput(t, b.Build(), `route="r1"`, `route="r2"`)
+
+	if got := testutil.ToFloat64(ScrapeErrorTotalMetric.WithLabelValues("routes")) - scrapeErrors; got != 1 {
+		t.Errorf("expected 1 failed list, got %v", got)
+	}
+	if got := testutil.ToFloat64(ListWatchRequestsMetric.WithLabelValues("routes", "list", "success")) - lists; got != 1 {
+		t.Errorf("expected 1 successful list, got %v", got)
+	}
+	if got := testutil.ToFloat64(StoreObjectsMetric.WithLabelValues("routes")); got != 2 {
+		t.Errorf("expected 2 objects in the store, got %v", got)
+	}
+	if got := initialListCount(t, "routes") - initialLists; got != 1 {
+		t.Errorf("expected 1 initial list duration, got %v", got)
+	}
+
+	// The self metrics of disabled collectors are dropped.
+	b.WithEnabledCollectors([]string{"groups"}).Build()
+	if StoreObjectsMetric.DeleteLabelValues("routes") || ScrapeErrorTotalMetric.DeleteLabelValues("routes") || InitialListDurationMetric.DeleteLabelValues("routes") {
+		t.Errorf("expected the self metrics of routes to be dropped")
+	}
+}
+
+// initialListCount returns the number of initial list durations observed for
+// the given collector.
+func initialListCount(t *testing.T, collector string) uint64 {
+	t.Helper()
+
+	m := &dto.Metric{}
+	if err := InitialListDurationMetric.WithLabelValues(collector).(prometheus.Histogram).Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestBuilderWatchEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clients := newFakeClientFactory()
+	clients.route = routefake.NewSimpleClientset(newTestRoute("ns1", "r1"))
+
+	events := func(eventType string) float64 {
+		return testutil.ToFloat64(WatchEventsMetric.WithLabelValues("routes", eventType))
+	}
+	added, deleted := events("added"), events("deleted")
+
+	collectors := newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"routes"}).
+		WithWatchEventsByNamespace(true).
+		Build()
+	waitForOutput(t, collectors, `route="r1"`)
+
+	routes := clients.route.RouteV1()
+	if _, err := routes.Routes("ns2").Create(ctx, newTestRoute("ns2", "r2"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForOutput(t, collectors, `route="r2"`)
+	if err := routes.Routes("ns1").Delete(ctx, "r1", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return !strings.Contains(collect(collectors), `route="r1"`), nil
+	})
+	if err != nil {
+		t.Fatalf("expected route r1 to be deleted")
+	}
+
+	// The initial list is not a watch event.
+	if got := events("added") - added; got != 1 {
+		t.Errorf("expected 1 added route, got %v", got)
+	}
+	if got := events("deleted") - deleted; got != 1 {
+		t.Errorf("expected 1 deleted route, got %v", got)
+	}
+	if got := testutil.ToFloat64(NamespaceWatchEventsMetric.WithLabelValues("routes", "ns2", "added")); got != 1 {
+		t.Errorf("expected 1 added route in ns2, got %v", got)
+	}
+	if got := testutil.ToFloat64(NamespaceWatchEventsMetric.WithLabelValues("routes", "ns1", "deleted")); got != 1 {
+		t.Errorf("expected 1 deleted route in ns1, got %v", got)
+	}
+}
@@ -0,0 +1,116 @@
+package collectors
+
+import (
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+	"k8s.io/kube-state-metrics/pkg/version"
+
+	appsclient "github.com/openshift/client-go/apps/clientset/versioned"
+	buildclient "github.com/openshift/client-go/build/clientset/versioned"
+	quotaclient "github.com/openshift/client-go/quota/clientset/versioned"
+	routeclient "github.com/openshift/client-go/route/clientset/versioned"
+	userclient "github.com/openshift/client-go/user/clientset/versioned"
+)
+
+// ClientFactory hands out the typed clients used by the collectors. Every
+// collector gets its client from the same factory, so that connections and
+// credentials are shared instead of being set up once per collector and
+// namespace.
+type ClientFactory interface {
+	Discovery() discovery.DiscoveryInterface
+	KubeClient() kubernetes.Interface
+	DynamicClient() dynamic.Interface
+	MetadataClient() metadata.Interface
+	AppsClient() appsclient.Interface
+	BuildClient() buildclient.Interface
+	QuotaClient() quotaclient.Interface
+	RouteClient() routeclient.Interface
+	UserClient() userclient.Interface
+}
+
+type clientFactory struct {
+	discovery discovery.DiscoveryInterface
+	kube      kubernetes.Interface
+	dynamic   dynamic.Interface
+	metadata  metadata.Interface
+	apps      appsclient.Interface
+	build     buildclient.Interface
+	quota     quotaclient.Interface
+	route     routeclient.Interface
+	user      userclient.Interface
+}
+
+// NewClientFactory returns a ClientFactory whose clients are all created from
+// the given REST config. The config is copied, so the caller can keep using
+// it.
+func NewClientFactory(config *rest.Config) (ClientFactory, error) {
+	config = rest.CopyConfig(config)
+	if config.UserAgent == "" {
+		config.UserAgent = version.GetVersion().String()
+	}
+	config.AcceptContentTypes = "application/vnd.kubernetes.protobuf,application/json"
+	config.ContentType = "application/vnd.kubernetes.protobuf"
+
+	// Clientsets created from the same config reuse one cached transport, see
+	// k8s.io/client-go/transport/cache.go.
+	var (
+		f   clientFactory
+		err error
+	)
+	if f.discovery, err = discovery.NewDiscoveryClientForConfig(config); err != nil {
+		return nil, err
+	}
+	if f.kube, err = kubernetes.NewForConfig(config); err != nil {
+		return nil, err
+	}
+	// The dynamic client only speaks JSON, it overrides the content types.
+	if f.dynamic, err = dynamic.NewForConfig(config); err != nil {
+		return nil, err
+	}
+	// The metadata client sets its own content types as well.
+	if f.metadata, err = metadata.NewForConfig(config); err != nil {
+		return nil, err
+	}
+	if f.apps, err = appsclient.NewForConfig(config); err != nil {
+		return nil, err
+	}
+	if f.build, err = buildclient.NewForConfig(config); err != nil {
+		return nil, err
+	}
+	if f.quota, err = quotaclient.NewForConfig(config); err != nil {
+		return nil, err
+	}
+	if f.route, err = routeclient.NewForConfig(config); err != nil {
+		return nil, err
+	}
+	if f.user, err = userclient.NewForConfig(config); err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+func (f *clientFactory) Discovery() discovery.DiscoveryInterface {
+	return f.discovery
+}
+
+func (f *clientFactory) KubeClient() kubernetes.Interface {
+	return f.kube
+}
+
+func (f *clientFactory) DynamicClient() dynamic.Interface {
+	return f.dynamic
+}
+
+func (f *clientFactory) MetadataClient() metadata.Interface {
+	return f.metadata
+}
+
+func (f *clientFactory) AppsClient() appsclient.Interface   { return f.apps }
+func (f *clientFactory) BuildClient() buildclient.Interface { return f.build }
+func (f *clientFactory) QuotaClient() quotaclient.Interface { return f.quota }
+func (f *clientFactory) RouteClient() routeclient.Interface { return f.route }
+func (f *clientFactory) UserClient() userclient.Interface   { return f.user }
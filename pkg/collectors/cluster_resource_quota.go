@@ -8,19 +8,17 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/kube-state-metrics/pkg/metric"
 
 	v1 "github.com/openshift/api/quota/v1"
-	quotaclient "github.com/openshift/client-go/quota/clientset/versioned"
-	"k8s.io/klog/v2"
-	"k8s.io/kube-state-metrics/pkg/version"
 )
 
 var (
 	descClusterResourceQuotaLabelsName          = "openshift_clusterresourcequota_labels"
 	descClusterResourceQuotaLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descClusterResourceQuotaLabelsDefaultLabels = []string{"name"}
+	descClusterResourceQuotaAnnotationsName     = "openshift_clusterresourcequota_annotations"
+	descClusterResourceQuotaAnnotationsHelp     = "Kubernetes annotations converted to Prometheus labels."
 
 	quotaMetricFamilies = []metric.FamilyGenerator{
 		metric.FamilyGenerator{
@@ -53,6 +51,22 @@ var (
 					}}
 			}),
 		},
+		metric.FamilyGenerator{
+			Name: descClusterResourceQuotaAnnotationsName,
+			Type: metric.MetricTypeGauge,
+			Help: descClusterResourceQuotaAnnotationsHelp,
+			GenerateFunc: wrapClusterResourceQuotaFunc(func(quota *v1.ClusterResourceQuota) metric.Family {
+				annotationKeys, annotationValues := kubeAnnotationsToPrometheusLabels(quota.Annotations)
+				return metric.Family{
+					Metrics: []*metric.Metric{
+						{
+							LabelKeys:   annotationKeys,
+							LabelValues: annotationValues,
+							Value:       1,
+						},
+					}}
+			}),
+		},
 		metric.FamilyGenerator{
 			Name: "openshift_clusterresourcequota_usage",
 			Type: metric.MetricTypeGauge,
@@ -170,32 +184,13 @@ func wrapClusterResourceQuotaFunc(f func(config *v1.ClusterResourceQuota) metric
 	}
 }
 
-func createClusterResourceQuotaListWatch(apiserver string, kubeconfig string, ns string) cache.ListWatch {
-	quotaclient, err := createClusterResourceQuotaClient(apiserver, kubeconfig)
-	if err != nil {
-		klog.Fatalf("cannot create quota client: %v", err)
-	}
+func createClusterResourceQuotaListWatch(clients ClientFactory, ns string) cache.ListWatch {
 	return cache.ListWatch{
 		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return quotaclient.QuotaV1().ClusterResourceQuotas().List(context.TODO(), opts)
+			return clients.QuotaClient().QuotaV1().ClusterResourceQuotas().List(context.TODO(), opts)
 		},
 		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return quotaclient.QuotaV1().ClusterResourceQuotas().Watch(context.TODO(), opts)
+			return clients.QuotaClient().QuotaV1().ClusterResourceQuotas().Watch(context.TODO(), opts)
 		},
 	}
 }
-
-func createClusterResourceQuotaClient(apiserver string, kubeconfig string) (*quotaclient.Clientset, error) {
-	config, err := clientcmd.BuildConfigFromFlags(apiserver, kubeconfig)
-	if err != nil {
-		return nil, err
-	}
-
-	config.UserAgent = version.GetVersion().String()
-	config.AcceptContentTypes = "application/vnd.kubernetes.protobuf,application/json"
-	config.ContentType = "application/vnd.kubernetes.protobuf"
-
-	client, err := quotaclient.NewForConfig(config)
-	return client, err
-
-}
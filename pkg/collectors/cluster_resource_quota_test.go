@@ -20,6 +20,8 @@ func TestClusterResourceQuotaCollector(t *testing.T) {
 		# TYPE openshift_clusterresourcequota_created gauge
 		# HELP openshift_clusterresourcequota_labels Kubernetes labels converted to Prometheus labels.
 		# TYPE openshift_clusterresourcequota_labels gauge
+		# HELP openshift_clusterresourcequota_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE openshift_clusterresourcequota_annotations gauge
 		# HELP openshift_clusterresourcequota_selector Selector of clusterresource quota, which defines the affected namespaces
 		# TYPE openshift_clusterresourcequota_selector gauge
 		# HELP openshift_clusterresourcequota_usage Usage about resource quota
@@ -136,6 +138,9 @@ func TestClusterResourceQuotaCollector(t *testing.T) {
 					Labels: map[string]string{
 						"quota": "test",
 					},
+					Annotations: map[string]string{
+						"owner": "team-a",
+					},
 				},
 				Spec: v1.ClusterResourceQuotaSpec{
 					Quota: corev1.ResourceQuotaSpec{
@@ -191,6 +196,7 @@ func TestClusterResourceQuotaCollector(t *testing.T) {
 			Want: `
        	openshift_clusterresourcequota_created{name="quota1"} 1.5e+09
         openshift_clusterresourcequota_labels{label_quota="test",name="quota1"} 1
+        openshift_clusterresourcequota_annotations{annotation_owner="team-a",name="quota1"} 1
         openshift_clusterresourcequota_usage{name="quota1",resource="configmaps",type="hard"} 4
         openshift_clusterresourcequota_usage{name="quota1",resource="configmaps",type="used"} 3
         openshift_clusterresourcequota_usage{name="quota1",resource="cpu",type="hard"} 4.3
@@ -0,0 +1,462 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"k8s.io/kube-state-metrics/pkg/metric"
+	"sigs.k8s.io/yaml"
+)
+
+// CustomMetricType is the type of a metric generated from a custom resource.
+type CustomMetricType string
+
+const (
+	// CustomMetricGauge takes its value from a field of the resource.
+	CustomMetricGauge CustomMetricType = "gauge"
+	// CustomMetricInfo always has the value 1 and carries its data in labels.
+	CustomMetricInfo CustomMetricType = "info"
+	// CustomMetricConditions generates one metric with the value 1 per
+	// element of a condition array, labeled with the type and status of the
+	// condition.
+	CustomMetricConditions CustomMetricType = "conditions"
+)
+
+var metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// CustomResourceStateConfig configures collectors for resources that have no
+// built-in collector, like custom resources or OpenShift operator resources.
+// Every resource gets a collector of its own.
+type CustomResourceStateConfig struct {
+	Resources []CustomResource `json:"resources"`
+}
+
+// CustomResource describes the metrics generated for a single kind.
+type CustomResource struct {
+	GroupVersionKind GroupVersionKind `json:"groupVersionKind"`
+	// Resource is the plural resource name. It defaults to the lowercase
+	// kind followed by "s".
+	Resource string `json:"resource,omitempty"`
+	// ClusterScoped must be set for resources which do not live in a
+	// namespace.
+	ClusterScoped bool `json:"clusterScoped,omitempty"`
+	// MetricNamePrefix is prepended to the name of every metric. It
+	// defaults to "openshift_" followed by the lowercase kind.
+	MetricNamePrefix *string `json:"metricNamePrefix,omitempty"`
+	// LabelsFromPath adds labels to every metric of the resource, taken
+	// from the given paths in the object.
+	LabelsFromPath map[string][]string `json:"labelsFromPath,omitempty"`
+	Metrics        []CustomMetric      `json:"metrics"`
+}
+
+// GroupVersionKind identifies the kind of a custom resource.
+type GroupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// CustomMetric describes a metric family generated for a custom resource.
+//
+// If Path points to an array, a metric is generated for every element of the
+// array and LabelsFromPath and ValueFrom are relative to the element.
+// Otherwise LabelsFromPath is relative to the object and a gauge takes its
+// value from Path.
+type CustomMetric struct {
+	Name           string              `json:"name"`
+	Help           string              `json:"help,omitempty"`
+	Type           CustomMetricType    `json:"type"`
+	Path           []string            `json:"path,omitempty"`
+	ValueFrom      []string            `json:"valueFrom,omitempty"`
+	LabelsFromPath map[string][]string `json:"labelsFromPath,omitempty"`
+	// NilIsZero makes a gauge report 0 instead of no metric when Path does
+	// not exist in the object.
+	NilIsZero bool `json:"nilIsZero,omitempty"`
+}
+
+// LoadCustomResourceStateConfig reads and validates the custom resource state
+// config at the given path.
+func LoadCustomResourceStateConfig(path string) (*CustomResourceStateConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config CustomResourceStateConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+
+	return &config, nil
+}
+
+// Validate checks that the config generates valid metrics, that no two
+// resources share a collector and that no metric family or label is generated
+// twice.
+func (c *CustomResourceStateConfig) Validate() error {
+	collectors := map[string]struct{}{}
+	// families maps the metric families to the collectors generating them.
+	families := map[string]string{}
+	for _, builtin := range AvailableCollectors() {
+		spec, _ := lookupCollector(builtin)
+		for _, f := range spec.families {
+			families[f.Name] = builtin
+		}
+	}
+	for i, r := range c.Resources {
+		gvk := r.GroupVersionKind
+		if gvk.Version == "" || gvk.Kind == "" {
+			return fmt.Errorf("resources[%d]: version and kind must be set", i)
+		}
+		name := r.collectorName()
+		for _, builtin := range AvailableCollectors() {
+			if spec, _ := lookupCollector(builtin); spec.resource.GroupResource() == r.groupVersionResource().GroupResource() {
+				return fmt.Errorf("resources[%d]: %s are covered by the built-in collector %s", i, name, builtin)
+			}
+		}
+		if _, ok := collectors[name]; ok {
+			return fmt.Errorf("resources[%d]: collector %s is configured twice", i, name)
+		}
+		collectors[name] = struct{}{}
+
+		if len(r.Metrics) == 0 {
+			return fmt.Errorf("resources[%d]: no metrics configured", i)
+		}
+		// Every metric is labeled with the name of the object and, for
+		// namespaced resources, its namespace.
+		labels := map[string]struct{}{"namespace": {}, r.kindLabel(): {}}
+		if err := validateLabels(r.LabelsFromPath, labels); err != nil {
+			return fmt.Errorf("resources[%d]: %v", i, err)
+		}
+		for name := range r.LabelsFromPath {
+			labels[name] = struct{}{}
+		}
+		for j, m := range r.Metrics {
+			if err := m.validate(r.metricNamePrefix(), labels); err != nil {
+				return fmt.Errorf("resources[%d].metrics[%d]: %v", i, j, err)
+			}
+			family := r.metricNamePrefix() + m.Name
+			if other, ok := families[family]; ok {
+				return fmt.Errorf("resources[%d].metrics[%d]: metric %s is already generated by collector %s", i, j, family, other)
+			}
+			families[family] = name
+		}
+	}
+
+	return nil
+}
+
+// validate checks the metric, whose metrics already carry the given labels.
+func (m CustomMetric) validate(prefix string, labels map[string]struct{}) error {
+	if !metricNameRE.MatchString(prefix + m.Name) {
+		return fmt.Errorf("invalid metric name %q", prefix+m.Name)
+	}
+	switch m.Type {
+	case CustomMetricGauge:
+		if len(m.Path) == 0 {
+			return fmt.Errorf("gauge %s has no path", m.Name)
+		}
+	case CustomMetricInfo:
+	case CustomMetricConditions:
+		reserved := make(map[string]struct{}, len(labels)+2)
+		for name := range labels {
+			reserved[name] = struct{}{}
+		}
+		reserved["type"], reserved["status"] = struct{}{}, struct{}{}
+		labels = reserved
+	default:
+		return fmt.Errorf("unknown metric type %q", m.Type)
+	}
+	return validateLabels(m.LabelsFromPath, labels)
+}
+
+// validateLabels checks the names and paths of the given labels, which must
+// not be one of the reserved labels.
+func validateLabels(labels map[string][]string, reserved map[string]struct{}) error {
+	for name, path := range labels {
+		if sanitizeLabelName(name) != name || name == "" {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if _, ok := reserved[name]; ok {
+			return fmt.Errorf("label %s is already set", name)
+		}
+		if len(path) == 0 {
+			return fmt.Errorf("label %s has no path", name)
+		}
+	}
+	return nil
+}
+
+// collectorName returns the name of the collector of the resource, e.g.
+// "ingresscontrollers.operator.openshift.io".
+func (r CustomResource) collectorName() string {
+	if r.GroupVersionKind.Group == "" {
+		return r.resource()
+	}
+	return r.resource() + "." + r.GroupVersionKind.Group
+}
+
+func (r CustomResource) resource() string {
+	if r.Resource != "" {
+		return r.Resource
+	}
+	return strings.ToLower(r.GroupVersionKind.Kind) + "s"
+}
+
+// kindLabel returns the name of the label holding the name of the object.
+func (r CustomResource) kindLabel() string {
+	return sanitizeLabelName(strings.ToLower(r.GroupVersionKind.Kind))
+}
+
+func (r CustomResource) metricNamePrefix() string {
+	if r.MetricNamePrefix != nil {
+		if *r.MetricNamePrefix == "" {
+			return ""
+		}
+		return *r.MetricNamePrefix + "_"
+	}
+	return "openshift_" + strings.ToLower(r.GroupVersionKind.Kind) + "_"
+}
+
+func (r CustomResource) groupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    r.GroupVersionKind.Group,
+		Version:  r.GroupVersionKind.Version,
+		Resource: r.resource(),
+	}
+}
+
+// collectorSpec returns the spec of the collector of the resource. Objects are
+// listed and watched through the dynamic client.
+func (r CustomResource) collectorSpec() collectorSpec {
+	gvr := r.groupVersionResource()
+
+	expectedType := &unstructured.Unstructured{}
+	expectedType.SetAPIVersion(schema.GroupVersion{Group: gvr.Group, Version: gvr.Version}.String())
+	expectedType.SetKind(r.GroupVersionKind.Kind)
+
+	scope := namespaceScoped
+	if r.ClusterScoped {
+		scope = clusterScoped
+	}
+
+	return collectorSpec{
+		resource:     gvr,
+		families:     r.metricFamilies(),
+		expectedType: expectedType,
+		scope:        scope,
+		labelNames:   r.labelNames(),
+		listWatchFunc: func(clients ClientFactory, ns string) cache.ListWatch {
+			return cache.ListWatch{
+				ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+					return clients.DynamicClient().Resource(gvr).Namespace(ns).List(context.TODO(), opts)
+				},
+				WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+					return clients.DynamicClient().Resource(gvr).Namespace(ns).Watch(context.TODO(), opts)
+				},
+			}
+		},
+	}
+}
+
+func (r CustomResource) metricFamilies() []metric.FamilyGenerator {
+	families := make([]metric.FamilyGenerator, len(r.Metrics))
+	for i, m := range r.Metrics {
+		m := m
+		help := m.Help
+		if help == "" {
+			help = fmt.Sprintf("%s of %s.", m.Name, r.GroupVersionKind.Kind)
+		}
+		families[i] = metric.FamilyGenerator{
+			Name:         r.metricNamePrefix() + m.Name,
+			Type:         metric.MetricTypeGauge,
+			Help:         help,
+			GenerateFunc: r.wrapFunc(m.generate),
+		}
+	}
+	return families
+}
+
+// labelNames returns the label names of the metrics of the resource, which do
+// not depend on the objects, keyed by metric name.
+func (r CustomResource) labelNames() map[string][]string {
+	defaultLabels := []string{r.kindLabel()}
+	if !r.ClusterScoped {
+		defaultLabels = []string{"namespace", r.kindLabel()}
+	}
+	keys, _ := labelsFromPath(nil, r.LabelsFromPath)
+	defaultLabels = append(defaultLabels, keys...)
+
+	labelNames := make(map[string][]string, len(r.Metrics))
+	for _, m := range r.Metrics {
+		keys, _ := labelsFromPath(nil, m.LabelsFromPath)
+		names := append(defaultLabels[:len(defaultLabels):len(defaultLabels)], keys...)
+		if m.Type == CustomMetricConditions {
+			names = append(names, "type", "status")
+		}
+		labelNames[r.metricNamePrefix()+m.Name] = names
+	}
+	return labelNames
+}
+
+// wrapFunc adds the default labels of the resource and the labels configured
+// for all of its metrics.
+func (r CustomResource) wrapFunc(f func(map[string]interface{}) metric.Family) func(interface{}) metric.Family {
+	kindLabel := r.kindLabel()
+	return func(obj interface{}) metric.Family {
+		u := obj.(*unstructured.Unstructured)
+
+		metricFamily := f(u.Object)
+
+		keys, values := []string{kindLabel}, []string{u.GetName()}
+		if !r.ClusterScoped {
+			keys, values = []string{"namespace", kindLabel}, []string{u.GetNamespace(), u.GetName()}
+		}
+		labelKeys, labelValues := labelsFromPath(u.Object, r.LabelsFromPath)
+		keys, values = append(keys, labelKeys...), append(values, labelValues...)
+
+		for _, m := range metricFamily.Metrics {
+			m.LabelKeys = append(keys[:len(keys):len(keys)], m.LabelKeys...)
+			m.LabelValues = append(values[:len(values):len(values)], m.LabelValues...)
+		}
+
+		return metricFamily
+	}
+}
+
+func (m CustomMetric) generate(obj map[string]interface{}) metric.Family {
+	f := metric.Family{}
+
+	path := m.Path
+	if m.Type == CustomMetricConditions && len(path) == 0 {
+		path = []string{"status", "conditions"}
+	}
+	var value interface{} = obj
+	found := true
+	if len(path) > 0 {
+		value, found, _ = unstructured.NestedFieldNoCopy(obj, path...)
+	}
+
+	elements, isList := value.([]interface{})
+	if !isList {
+		if m.Type == CustomMetricConditions || (!found && !(m.Type == CustomMetricGauge && m.NilIsZero)) {
+			return f
+		}
+		labelKeys, labelValues := labelsFromPath(obj, m.LabelsFromPath)
+		v := 1.0
+		if m.Type == CustomMetricGauge && found {
+			var err error
+			if v, err = customResourceValue(value); err != nil {
+				klog.V(4).Infof("skipping metric %s: %v", m.Name, err)
+				return f
+			}
+		} else if m.Type == CustomMetricGauge {
+			v = 0
+		}
+		f.Metrics = append(f.Metrics, &metric.Metric{LabelKeys: labelKeys, LabelValues: labelValues, Value: v})
+		return f
+	}
+
+	for _, e := range elements {
+		element, _ := e.(map[string]interface{})
+		labelKeys, labelValues := labelsFromPath(element, m.LabelsFromPath)
+		v := 1.0
+		switch m.Type {
+		case CustomMetricGauge:
+			var value interface{} = e
+			if len(m.ValueFrom) > 0 {
+				var found bool
+				if value, found, _ = unstructured.NestedFieldNoCopy(element, m.ValueFrom...); !found {
+					continue
+				}
+			}
+			var err error
+			if v, err = customResourceValue(value); err != nil {
+				klog.V(4).Infof("skipping metric %s: %v", m.Name, err)
+				continue
+			}
+		case CustomMetricConditions:
+			labelKeys = append(labelKeys, "type", "status")
+			labelValues = append(labelValues, labelString(element["type"]), labelString(element["status"]))
+		}
+		f.Metrics = append(f.Metrics, &metric.Metric{LabelKeys: labelKeys, LabelValues: labelValues, Value: v})
+	}
+
+	return f
+}
+
+// labelsFromPath returns the labels taken from the given paths in obj, sorted
+// by label name. Labels whose path does not exist are empty.
+func labelsFromPath(obj map[string]interface{}, labels map[string][]string) ([]string, []string) {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		if v, found, _ := unstructured.NestedFieldNoCopy(obj, labels[k]...); found {
+			values[i] = labelString(v)
+		}
+	}
+	return keys, values
+}
+
+func labelString(v interface{}) string {
+	switch v := v.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// customResourceValue converts a field of an object to a metric value.
+// Besides numbers it accepts booleans, RFC 3339 timestamps, which are converted
+// to Unix timestamps, and quantities like "500m" or "1Gi".
+func customResourceValue(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case bool:
+		return boolFloat64(v), nil
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, nil
+		}
+		if b, err := strconv.ParseBool(v); err == nil {
+			return boolFloat64(b), nil
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return float64(t.Unix()), nil
+		}
+		if q, err := resource.ParseQuantity(v); err == nil {
+			return q.AsApproximateFloat64(), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot convert %v to a metric value", v)
+}
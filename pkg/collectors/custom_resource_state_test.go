@@ -0,0 +1,263 @@
+package collectors
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/kube-state-metrics/pkg/metric"
+	"k8s.io/kube-state-metrics/pkg/options"
+	"k8s.io/kube-state-metrics/pkg/whiteblacklist"
+	"sigs.k8s.io/yaml"
+)
+
+const widgetConfig = `
+resources:
+- groupVersionKind:
+    group: example.com
+    version: v1
+    kind: Widget
+  metricNamePrefix: example_widget
+  labelsFromPath:
+    team: [metadata, labels, team]
+  metrics:
+  - name: replicas
+    help: Desired number of replicas.
+    type: gauge
+    path: [spec, replicas]
+  - name: paused
+    type: gauge
+    path: [spec, paused]
+    nilIsZero: true
+  - name: memory_bytes
+    help: Memory limit.
+    type: gauge
+    path: [spec, memory]
+  - name: info
+    help: Information about the widget.
+    type: info
+    labelsFromPath:
+      version: [spec, version]
+  - name: endpoint_ready
+    help: Readiness of each endpoint.
+    type: gauge
+    path: [status, endpoints]
+    valueFrom: [ready]
+    labelsFromPath:
+      endpoint: [name]
+  - name: condition
+    help: The conditions of the widget.
+    type: conditions
+`
+
+func newTestWidget(ns, name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": ns,
+			"uid":       ns + "/" + name,
+			"labels":    map[string]interface{}{"team": "a"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"memory":   "1Gi",
+			"version":  "1.2",
+		},
+		"status": map[string]interface{}{
+			"endpoints": []interface{}{
+				map[string]interface{}{"name": "e1", "ready": true},
+				map[string]interface{}{"name": "e2", "ready": false},
+			},
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Available", "status": "True"},
+				map[string]interface{}{"type": "Degraded", "status": "False"},
+			},
+		},
+	}}
+}
+
+func TestCustomResourceStateCollector(t *testing.T) {
+	var config CustomResourceStateConfig
+	if err := yaml.UnmarshalStrict([]byte(widgetConfig), &config); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []generateMetricsTestCase{
+		{
+			Obj: newTestWidget("ns1", "widget1"),
+			Want: `
+				example_widget_replicas{namespace="ns1",widget="widget1",team="a"} 3
+				example_widget_paused{namespace="ns1",widget="widget1",team="a"} 0
+				example_widget_memory_bytes{namespace="ns1",widget="widget1",team="a"} 1.073741824e+09
+				example_widget_info{namespace="ns1",widget="widget1",team="a",version="1.2"} 1
+				example_widget_endpoint_ready{namespace="ns1",widget="widget1",team="a",endpoint="e1"} 1
+				example_widget_endpoint_ready{namespace="ns1",widget="widget1",team="a",endpoint="e2"} 0
+				example_widget_condition{namespace="ns1",widget="widget1",team="a",type="Available",status="True"} 1
+				example_widget_condition{namespace="ns1",widget="widget1",team="a",type="Degraded",status="False"} 1
+			`,
+		},
+		{
+			Obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "widget2", "namespace": "ns2"},
+				"spec":     map[string]interface{}{"memory": "not a quantity"},
+			}},
+			Want: `
+				example_widget_paused{namespace="ns2",widget="widget2",team=""} 0
+				example_widget_info{namespace="ns2",widget="widget2",team="",version=""} 1
+			`,
+		},
+	}
+	for i, c := range cases {
+		c.Func = metric.ComposeMetricGenFuncs(config.Resources[0].metricFamilies())
+		if err := c.run(); err != nil {
+			t.Errorf("unexpected collecting result in %vth run:\n%s", i, err)
+		}
+	}
+}
+
+func TestCustomResourceStateConfigDefaults(t *testing.T) {
+	r := CustomResource{
+		GroupVersionKind: GroupVersionKind{Group: "operator.openshift.io", Version: "v1", Kind: "IngressController"},
+		Metrics:          []CustomMetric{{Name: "info", Type: CustomMetricInfo}},
+	}
+
+	if name := r.collectorName(); name != "ingresscontrollers.operator.openshift.io" {
+		t.Errorf("unexpected collector name %q", name)
+	}
+	if name := r.metricFamilies()[0].Name; name != "openshift_ingresscontroller_info" {
+		t.Errorf("unexpected metric name %q", name)
+	}
+}
+
+func TestCustomResourceStateConfigValidate(t *testing.T) {
+	cases := map[string]struct {
+		config string
+		want   string
+	}{
+		"unknown type": {`
+resources:
+- groupVersionKind: {version: v1, kind: Widget}
+  metrics:
+  - {name: foo, type: histogram}`, "unknown metric type"},
+		"gauge without path": {`
+resources:
+- groupVersionKind: {version: v1, kind: Widget}
+  metrics:
+  - {name: foo, type: gauge}`, "has no path"},
+		"invalid label": {`
+resources:
+- groupVersionKind: {version: v1, kind: Widget}
+  metrics:
+  - {name: foo, type: info, labelsFromPath: {"my-label": [spec]}}`, "invalid label name"},
+		"built-in collector": {`
+resources:
+- groupVersionKind: {group: route.openshift.io, version: v1, kind: Route}
+  metrics:
+  - {name: foo, type: info}`, "covered by the built-in collector"},
+		"unknown field": {`
+resources:
+- groupVersionKind: {version: v1, kind: Widget}
+  metric: []`, "unknown field"},
+		"duplicate metric": {`
+resources:
+- groupVersionKind: {version: v1, kind: Widget}
+  metrics:
+  - {name: foo, type: info}
+  - {name: foo, type: gauge, path: [spec, replicas]}`, "openshift_widget_foo is already generated by collector widgets"},
+		"duplicate family across resources": {`
+resources:
+- groupVersionKind: {group: a.example.com, version: v1, kind: Widget}
+  metrics:
+  - {name: info, type: info}
+- groupVersionKind: {group: b.example.com, version: v1, kind: Widget}
+  metrics:
+  - {name: info, type: info}`, "openshift_widget_info is already generated by collector widgets.a.example.com"},
+		"built-in family": {`
+resources:
+- groupVersionKind: {group: example.com, version: v1, kind: Route}
+  metrics:
+  - {name: info, type: info}`, "openshift_route_info is already generated by collector routes"},
+		"label of resource and metric": {`
+resources:
+- groupVersionKind: {version: v1, kind: Widget}
+  labelsFromPath: {owner: [spec, owner]}
+  metrics:
+  - {name: foo, type: info, labelsFromPath: {owner: [metadata, name]}}`, "label owner is already set"},
+		"namespace label": {`
+resources:
+- groupVersionKind: {version: v1, kind: Widget}
+  labelsFromPath: {namespace: [spec, namespace]}
+  metrics:
+  - {name: foo, type: info}`, "label namespace is already set"},
+		"name label": {`
+resources:
+- groupVersionKind: {version: v1, kind: Widget}
+  metrics:
+  - {name: foo, type: info, labelsFromPath: {widget: [spec, name]}}`, "label widget is already set"},
+		"condition label": {`
+resources:
+- groupVersionKind: {version: v1, kind: Widget}
+  metrics:
+  - {name: foo, type: conditions, labelsFromPath: {status: [reason]}}`, "label status is already set"},
+	}
+
+	dir := t.TempDir()
+	for name, c := range cases {
+		path := filepath.Join(dir, "config.yaml")
+		if err := os.WriteFile(path, []byte(c.config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadCustomResourceStateConfig(path); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, c.want, err)
+		}
+	}
+}
+
+func TestBuilderCustomResourceState(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var config CustomResourceStateConfig
+	if err := yaml.UnmarshalStrict([]byte(widgetConfig), &config); err != nil {
+		t.Fatal(err)
+	}
+	gvr := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+
+	clients := newFakeClientFactory()
+	clients.discovery.setServed(gvr, true)
+	clients.dynamic = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "WidgetList"},
+		newTestWidget("ns1", "widget1"),
+	)
+
+	l, err := whiteblacklist.New(options.MetricSet{}, options.MetricSet{"example_widget_info": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collectors := newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"routes"}).
+		WithCustomResourceState(&config).
+		WithWhiteBlackList(l).
+		Build()
+
+	out := waitForOutput(t, collectors,
+		`example_widget_replicas{namespace="ns1",widget="widget1",team="a"} 3`,
+		`example_widget_condition{namespace="ns1",widget="widget1",team="a",type="Available",status="True"} 1`,
+	)
+	if strings.Contains(out, "example_widget_info") {
+		t.Errorf("expected blacklisted metric to be filtered out, got:\n%s", out)
+	}
+}
@@ -8,20 +8,17 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/kube-state-metrics/pkg/metric"
-	"k8s.io/kube-state-metrics/pkg/version"
-
-	"k8s.io/klog/v2"
 
 	v1 "github.com/openshift/api/apps/v1"
-	appsclient "github.com/openshift/client-go/apps/clientset/versioned"
 )
 
 var (
 	descDeploymentLabelsName          = "openshift_deploymentconfig_labels"
 	descDeploymentLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descDeploymentLabelsDefaultLabels = []string{"namespace", "deploymentconfig"}
+	descDeploymentAnnotationsName     = "openshift_deploymentconfig_annotations"
+	descDeploymentAnnotationsHelp     = "Kubernetes annotations converted to Prometheus labels."
 
 	deploymentMetricFamilies = []metric.FamilyGenerator{
 		metric.FamilyGenerator{
@@ -193,6 +190,21 @@ var (
 				}}
 			}),
 		},
+		metric.FamilyGenerator{
+			Name: descDeploymentAnnotationsName,
+			Type: metric.MetricTypeGauge,
+			Help: descDeploymentAnnotationsHelp,
+			GenerateFunc: wrapDeploymentFunc(func(d *v1.DeploymentConfig) metric.Family {
+				annotationKeys, annotationValues := kubeAnnotationsToPrometheusLabels(d.Annotations)
+				return metric.Family{Metrics: []*metric.Metric{
+					{
+						LabelKeys:   annotationKeys,
+						LabelValues: annotationValues,
+						Value:       1,
+					},
+				}}
+			}),
+		},
 	}
 )
 
@@ -211,32 +223,13 @@ func wrapDeploymentFunc(f func(*v1.DeploymentConfig) metric.Family) func(interfa
 	}
 }
 
-func createDeploymentListWatch(apiserver string, kubeconfig string, ns string) cache.ListWatch {
-	appsclient, err := createAppsClient(apiserver, kubeconfig)
-	if err != nil {
-		klog.Fatalf("cannot create deploymentconfig client: %v", err)
-	}
+func createDeploymentListWatch(clients ClientFactory, ns string) cache.ListWatch {
 	return cache.ListWatch{
 		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return appsclient.AppsV1().DeploymentConfigs(ns).List(context.TODO(), opts)
+			return clients.AppsClient().AppsV1().DeploymentConfigs(ns).List(context.TODO(), opts)
 		},
 		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return appsclient.AppsV1().DeploymentConfigs(ns).Watch(context.TODO(), opts)
+			return clients.AppsClient().AppsV1().DeploymentConfigs(ns).Watch(context.TODO(), opts)
 		},
 	}
 }
-
-func createAppsClient(apiserver string, kubeconfig string) (*appsclient.Clientset, error) {
-	config, err := clientcmd.BuildConfigFromFlags(apiserver, kubeconfig)
-	if err != nil {
-		return nil, err
-	}
-
-	config.UserAgent = version.GetVersion().String()
-	config.AcceptContentTypes = "application/vnd.kubernetes.protobuf,application/json"
-	config.ContentType = "application/vnd.kubernetes.protobuf"
-
-	client, err := appsclient.NewForConfig(config)
-	return client, err
-
-}
@@ -51,6 +51,8 @@ func TestDeploymentCollector(t *testing.T) {
 		# TYPE openshift_deploymentconfig_spec_strategy_rollingupdate_max_surge gauge
 		# HELP openshift_deploymentconfig_labels Kubernetes labels converted to Prometheus labels.
 		# TYPE openshift_deploymentconfig_labels gauge
+		# HELP openshift_deploymentconfig_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE openshift_deploymentconfig_annotations gauge
 	`
 	cases := []generateMetricsTestCase{
 		{
@@ -84,6 +86,7 @@ func TestDeploymentCollector(t *testing.T) {
 			Want: `
         openshift_deploymentconfig_created{deploymentconfig="depl1",namespace="ns1"} 1.5e+09
         openshift_deploymentconfig_labels{deploymentconfig="depl1",label_app="example1",namespace="ns1"} 1
+        openshift_deploymentconfig_annotations{deploymentconfig="depl1",namespace="ns1"} 1
         openshift_deploymentconfig_metadata_generation{deploymentconfig="depl1",namespace="ns1"} 21
         openshift_deploymentconfig_spec_paused{deploymentconfig="depl1",namespace="ns1"} 0
         openshift_deploymentconfig_spec_replicas{deploymentconfig="depl1",namespace="ns1"} 200
@@ -126,6 +129,7 @@ func TestDeploymentCollector(t *testing.T) {
 			},
 			Want: `
        	openshift_deploymentconfig_labels{deploymentconfig="depl2",label_app="example2",namespace="ns2"} 1
+       	openshift_deploymentconfig_annotations{deploymentconfig="depl2",namespace="ns2"} 1
         openshift_deploymentconfig_metadata_generation{deploymentconfig="depl2",namespace="ns2"} 14
         openshift_deploymentconfig_spec_paused{deploymentconfig="depl2",namespace="ns2"} 1
         openshift_deploymentconfig_spec_replicas{deploymentconfig="depl2",namespace="ns2"} 5
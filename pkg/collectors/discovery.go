@@ -0,0 +1,124 @@
+package collectors
+
+import (
+	"context"
+	"math"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog/v2"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	reasonAPIServed       = "api_served"
+	reasonAPINotServed    = "api_not_served"
+	reasonDiscoveryFailed = "discovery_failed"
+)
+
+var (
+	// apiDiscoveryInterval is how often the API discovery is checked again
+	// for collectors whose resource is not served yet.
+	apiDiscoveryInterval = time.Minute
+	// discoveryRetryInterval is how soon a failed API discovery is retried.
+	// The delay doubles with every failure, up to apiDiscoveryInterval.
+	discoveryRetryInterval = time.Second
+
+	CollectorEnabledMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openshift_state_metrics_collector_enabled",
+			Help: "Whether a collector is running (1) or waiting for its API to be served (0).",
+		},
+		[]string{"collector", "reason"},
+	)
+)
+
+// resourceServed checks via API discovery whether the apiserver serves the
+// given resource. It returns the reason to report in CollectorEnabledMetric.
+func resourceServed(client discovery.DiscoveryInterface, gvr schema.GroupVersionResource) (bool, string) {
+	resources, err := client.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, reasonAPINotServed
+		}
+		klog.Warningf("cannot discover %s: %v", gvr.GroupVersion(), err)
+		return false, reasonDiscoveryFailed
+	}
+
+	for _, r := range resources.APIResources {
+		if r.Name == gvr.Resource {
+			return true, reasonAPIServed
+		}
+	}
+
+	return false, reasonAPINotServed
+}
+
+// startWhenServed calls start as soon as the resource of the given collector
+// is served by the apiserver. If it is not served yet, the API discovery is
+// checked again every apiDiscoveryInterval until it is or ctx is done. Failed
+// discoveries are retried sooner, with a backoff. Whether the last discovery
+// failed is recorded in the health of the collector.
+func startWhenServed(ctx context.Context, client discovery.DiscoveryInterface, name string, gvr schema.GroupVersionResource, health *collectorHealth, start func()) {
+	check := func() (bool, string) {
+		served, reason := resourceServed(client, gvr)
+		setCollectorEnabled(name, served, reason)
+		health.setDiscoveryFailed(reason == reasonDiscoveryFailed)
+		if served {
+			start()
+		}
+		return served, reason
+	}
+
+	served, reason := check()
+	if served {
+		return
+	}
+
+	if reason == reasonDiscoveryFailed {
+		klog.Warningf("collector %s is not started: API discovery of %s failed, retrying", name, gvr.GroupVersion())
+	} else {
+		klog.Warningf("collector %s is not started: %s is not served in %s, checking again every %s", name, gvr.Resource, gvr.GroupVersion(), apiDiscoveryInterval)
+	}
+	go func() {
+		backoff := newDiscoveryBackoff()
+		for {
+			interval := apiDiscoveryInterval
+			if reason == reasonDiscoveryFailed {
+				interval = backoff.Step()
+			} else {
+				backoff = newDiscoveryBackoff()
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			if served, reason = check(); served {
+				klog.Infof("collector %s is started: %s is served in %s now", name, gvr.Resource, gvr.GroupVersion())
+				return
+			}
+		}
+	}()
+}
+
+// newDiscoveryBackoff returns the backoff of failed API discoveries.
+func newDiscoveryBackoff() wait.Backoff {
+	return wait.Backoff{
+		Duration: discoveryRetryInterval,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    math.MaxInt32,
+		Cap:      apiDiscoveryInterval,
+	}
+}
+
+func setCollectorEnabled(name string, enabled bool, reason string) {
+	CollectorEnabledMetric.DeletePartialMatch(prometheus.Labels{"collector": name})
+	CollectorEnabledMetric.WithLabelValues(name, reason).Set(boolFloat64(enabled))
+}
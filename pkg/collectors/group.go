@@ -7,14 +7,9 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/kube-state-metrics/pkg/metric"
-	"k8s.io/kube-state-metrics/pkg/version"
-
-	"k8s.io/klog/v2"
 
 	v1 "github.com/openshift/api/user/v1"
-	groupclient "github.com/openshift/client-go/user/clientset/versioned"
 )
 
 var (
@@ -76,32 +71,13 @@ func wrapGroupFunc(f func(group *v1.Group) metric.Family) func(interface{}) metr
 	}
 }
 
-func createGroupListWatch(apiserver string, kubeconfig string, ns string) cache.ListWatch {
-	groupclient, err := createGroupClient(apiserver, kubeconfig)
-	if err != nil {
-		klog.Fatalf("cannot create Group client: %v", err)
-	}
+func createGroupListWatch(clients ClientFactory, ns string) cache.ListWatch {
 	return cache.ListWatch{
 		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return groupclient.UserV1().Groups().List(context.TODO(), opts)
+			return clients.UserClient().UserV1().Groups().List(context.TODO(), opts)
 		},
 		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return groupclient.UserV1().Groups().Watch(context.TODO(), opts)
+			return clients.UserClient().UserV1().Groups().Watch(context.TODO(), opts)
 		},
 	}
 }
-
-func createGroupClient(apiserver string, kubeconfig string) (*groupclient.Clientset, error) {
-	config, err := clientcmd.BuildConfigFromFlags(apiserver, kubeconfig)
-	if err != nil {
-		return nil, err
-	}
-
-	config.UserAgent = version.GetVersion().String()
-	config.AcceptContentTypes = "application/vnd.kubernetes.protobuf,application/json"
-	config.ContentType = "application/vnd.kubernetes.protobuf"
-
-	client, err := groupclient.NewForConfig(config)
-	return client, err
-
-}
@@ -0,0 +1,197 @@
+package collectors
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CollectorStatus describes the health of a single collector.
+type CollectorStatus struct {
+	Name string `json:"name"`
+	// Started is false as long as the API of the collector is not served.
+	Started bool `json:"started"`
+	// DiscoveryFailed is true while the collector is not started because
+	// the last API discovery of its resource failed.
+	DiscoveryFailed bool `json:"discoveryFailed,omitempty"`
+	// Synced is true once every reflector of the collector finished its
+	// initial list.
+	Synced bool `json:"synced"`
+	// LastSuccess is the oldest time any reflector of the collector last
+	// listed or watched successfully. Reflectors which never succeeded count
+	// with the time they were started.
+	LastSuccess time.Time `json:"lastSuccess,omitempty"`
+}
+
+// collectorHealth tracks the reflectors of a single collector.
+type collectorHealth struct {
+	// name is the name of the collector in the self metrics.
+	name string
+
+	mu              sync.RWMutex
+	started         bool
+	discoveryFailed bool
+	reflectors      map[string]*reflectorHealth
+}
+
+type reflectorHealth struct {
+	collector string
+	// created is when the reflector was started.
+	created time.Time
+
+	mu          sync.RWMutex
+	synced      bool
+	lastSuccess time.Time
+	// watches is the number of watches the reflector started.
+	watches int
+}
+
+func newCollectorHealth(name string) *collectorHealth {
+	return &collectorHealth{name: name, reflectors: map[string]*reflectorHealth{}}
+}
+
+// start marks the collector as started.
+func (h *collectorHealth) start() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.started = true
+}
+
+// setDiscoveryFailed records whether the last API discovery of the resource
+// of the collector failed.
+func (h *collectorHealth) setDiscoveryFailed(failed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.discoveryFailed = failed
+}
+
+// newReflector registers a new reflector for the given namespace with the
+// collector. It replaces any previous reflector of that namespace.
+func (h *collectorHealth) newReflector(ns string) *reflectorHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	r := &reflectorHealth{collector: h.name, created: now, lastSuccess: now}
+	h.reflectors[ns] = r
+	// Expose the successful requests right away, so that a collector which
+	// never succeeds shows up as stale too.
+	ListWatchRequestsMetric.WithLabelValues(h.name, "list", "success")
+	ListWatchRequestsMetric.WithLabelValues(h.name, "watch", "success")
+
+	return r
+}
+
+func (h *collectorHealth) removeReflector(ns string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.reflectors, ns)
+}
+
+func (h *collectorHealth) status(name string) CollectorStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	s := CollectorStatus{Name: name, Started: h.started, Synced: h.started, DiscoveryFailed: !h.started && h.discoveryFailed}
+	for _, r := range h.reflectors {
+		r.mu.RLock()
+		s.Synced = s.Synced && r.synced
+		if s.LastSuccess.IsZero() || r.lastSuccess.Before(s.LastSuccess) {
+			s.LastSuccess = r.lastSuccess
+		}
+		r.mu.RUnlock()
+	}
+
+	return s
+}
+
+func (r *reflectorHealth) succeeded(synced bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastSuccess = time.Now()
+	if synced && !r.synced {
+		InitialListDurationMetric.WithLabelValues(r.collector).Observe(r.lastSuccess.Sub(r.created).Seconds())
+	}
+	r.synced = r.synced || synced
+}
+
+// instrument wraps the given ListWatch, so that every successful list and
+// watch counts as a sign of life. Both are counted in the self metrics.
+func (r *reflectorHealth) instrument(lw cache.ListWatch) cache.ListWatch {
+	list, watchFunc := lw.ListFunc, lw.WatchFunc
+	lw.ListFunc = func(opts metav1.ListOptions) (runtime.Object, error) {
+		start := time.Now()
+		obj, err := list(opts)
+		ListDurationMetric.WithLabelValues(r.collector).Observe(time.Since(start).Seconds())
+		if err != nil {
+			ListWatchRequestsMetric.WithLabelValues(r.collector, "list", "error").Inc()
+			ScrapeErrorTotalMetric.WithLabelValues(r.collector).Inc()
+			return obj, err
+		}
+		ListWatchRequestsMetric.WithLabelValues(r.collector, "list", "success").Inc()
+		ResourcesPerScrapeMetric.WithLabelValues(r.collector).Observe(float64(meta.LenList(obj)))
+		// The reflector is synced once it replaced the store content with
+		// the list result, see syncStore.
+		r.succeeded(false)
+		return obj, err
+	}
+	lw.WatchFunc = func(opts metav1.ListOptions) (watch.Interface, error) {
+		r.mu.Lock()
+		r.watches++
+		restart := r.watches > 1
+		r.mu.Unlock()
+		if restart {
+			WatchRestartsMetric.WithLabelValues(r.collector).Inc()
+		}
+
+		w, err := watchFunc(opts)
+		if err != nil {
+			ListWatchRequestsMetric.WithLabelValues(r.collector, "watch", "error").Inc()
+			return w, err
+		}
+		ListWatchRequestsMetric.WithLabelValues(r.collector, "watch", "success").Inc()
+		r.succeeded(false)
+		return w, err
+	}
+	return lw
+}
+
+// syncStore marks a reflector as synced as soon as the reflector replaced
+// the content of the store with its initial list.
+type syncStore struct {
+	cache.Store
+	health *reflectorHealth
+}
+
+func (s *syncStore) Replace(list []interface{}, resourceVersion string) error {
+	if err := s.Store.Replace(list, resourceVersion); err != nil {
+		return err
+	}
+	s.health.succeeded(true)
+	return nil
+}
+
+// Status returns the health of all collectors built by the Builder, sorted by
+// name.
+func (b *Builder) Status() []CollectorStatus {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	statuses := make([]CollectorStatus, 0, len(b.built))
+	for name, c := range b.built {
+		statuses = append(statuses, c.health.status(name))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+
+	return statuses
+}
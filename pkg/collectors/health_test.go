@@ -0,0 +1,79 @@
+package collectors
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/kube-state-metrics/pkg/metric"
+	metricsstore "k8s.io/kube-state-metrics/pkg/metrics_store"
+)
+
+func TestBuilderStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clients := newFakeClientFactory()
+	clients.discovery.setServed(availableCollectors["builds"].resource, false)
+
+	start := time.Now()
+	b := newTestBuilder(t, ctx, clients).WithEnabledCollectors([]string{"builds", "routes"})
+	b.Build()
+
+	var statuses []CollectorStatus
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		statuses = b.Status()
+		return statuses[1].Synced, nil
+	})
+	if err != nil {
+		t.Fatalf("expected routes collector to sync, got %+v", statuses)
+	}
+
+	if s := statuses[0]; s.Name != "builds" || s.Started || s.Synced {
+		t.Errorf("expected builds collector not to be started, got %+v", s)
+	}
+	if s := statuses[1]; s.Name != "routes" || !s.Started || s.LastSuccess.Before(start) {
+		t.Errorf("expected routes collector to have listed successfully, got %+v", s)
+	}
+}
+
+func TestCollectorHealthNotSyncedUntilAllReflectorsSynced(t *testing.T) {
+	h := newCollectorHealth("test")
+	if s := h.status("test"); s.Started || s.Synced {
+		t.Fatalf("expected collector not to be started, got %+v", s)
+	}
+
+	h.start()
+	if s := h.status("test"); !s.Started || !s.Synced {
+		t.Fatalf("expected started collector without reflectors to be synced, got %+v", s)
+	}
+
+	r1, r2 := h.newReflector("a"), h.newReflector("b")
+	r1.succeeded(true)
+	if s := h.status("test"); !s.Started || s.Synced {
+		t.Fatalf("expected collector not to be synced with a pending reflector, got %+v", s)
+	}
+
+	r2.succeeded(false)
+	if s := h.status("test"); s.Synced {
+		t.Fatalf("expected collector not to be synced before the store was replaced, got %+v", s)
+	}
+
+	store := metricsstore.NewMetricsStore(nil, metric.ComposeMetricGenFuncs(nil))
+	if err := (&syncStore{Store: store, health: r2}).Replace(nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if s := h.status("test"); !s.Synced {
+		t.Fatalf("expected collector to be synced, got %+v", s)
+	}
+
+	h.newReflector("c")
+	if s := h.status("test"); s.Synced {
+		t.Fatalf("expected collector not to be synced with a new reflector, got %+v", s)
+	}
+	h.removeReflector("c")
+	if s := h.status("test"); !s.Synced {
+		t.Fatalf("expected collector to be synced after removing the new reflector, got %+v", s)
+	}
+}
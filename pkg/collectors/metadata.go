@@ -0,0 +1,80 @@
+package collectors
+
+import (
+	"context"
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/kube-state-metrics/pkg/metric"
+)
+
+// onlyMetadataFamilies tells whether all of the given families are metadata
+// families, which only read the ObjectMeta of the objects.
+func onlyMetadataFamilies(families []metric.FamilyGenerator, metadataFamilies []string) bool {
+	allowed := make(map[string]bool, len(metadataFamilies))
+	for _, name := range metadataFamilies {
+		allowed[name] = true
+	}
+	for _, f := range families {
+		if !allowed[f.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// createMetadataListWatch lists and watches only the metadata of the objects
+// of the given resource, as PartialObjectMetadata.
+func createMetadataListWatch(clients ClientFactory, resource schema.GroupVersionResource, ns string) cache.ListWatch {
+	return cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return clients.MetadataClient().Resource(resource).Namespace(ns).List(context.TODO(), opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return clients.MetadataClient().Resource(resource).Namespace(ns).Watch(context.TODO(), opts)
+		},
+	}
+}
+
+// metadataStore converts the PartialObjectMetadata of metadata-only watches to
+// the objects the metric families expect, with only their ObjectMeta set.
+type metadataStore struct {
+	cache.Store
+	// objectType is the struct type of the objects, e.g. routev1.Route.
+	objectType reflect.Type
+}
+
+func (s *metadataStore) Add(obj interface{}) error {
+	return s.Store.Add(s.convert(obj))
+}
+
+func (s *metadataStore) Update(obj interface{}) error {
+	return s.Store.Update(s.convert(obj))
+}
+
+func (s *metadataStore) Delete(obj interface{}) error {
+	return s.Store.Delete(s.convert(obj))
+}
+
+func (s *metadataStore) Replace(list []interface{}, resourceVersion string) error {
+	converted := make([]interface{}, len(list))
+	for i, obj := range list {
+		converted[i] = s.convert(obj)
+	}
+	return s.Store.Replace(converted, resourceVersion)
+}
+
+func (s *metadataStore) convert(obj interface{}) interface{} {
+	m, ok := obj.(*metav1.PartialObjectMetadata)
+	if !ok {
+		return obj
+	}
+	o := reflect.New(s.objectType)
+	o.Elem().FieldByName("TypeMeta").Set(reflect.ValueOf(m.TypeMeta))
+	o.Elem().FieldByName("ObjectMeta").Set(reflect.ValueOf(m.ObjectMeta))
+	return o.Interface()
+}
@@ -0,0 +1,319 @@
+package collectors
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	metricsstore "k8s.io/kube-state-metrics/pkg/metrics_store"
+)
+
+// MetricsStore implements the k8s.io/client-go/tools/cache.Store interface.
+// Like the kube-state-metrics MetricsStore, it stores the metrics generated
+// for each object instead of the object itself. On top of that it knows the
+// namespace of each object, so that several reflectors, one per namespace, can
+// share a single store without overwriting each other on relist.
+type MetricsStore struct {
+	// Protects metrics
+	mutex sync.RWMutex
+	// metrics is a map indexed by Kubernetes object id, containing the
+	// namespace of the object and its metrics grouped by metric family.
+	metrics map[types.UID]*objectMetrics
+	// headers contains the header (TYPE and HELP) of each metric family.
+	headers []string
+	// lastEvent is the time an object was last added, updated or deleted.
+	lastEvent time.Time
+	// objects is set to the number of objects in the store, if not nil.
+	objects prometheus.Gauge
+	// namespaceLabels, if not nil, drops the series whose namespace label
+	// it does not allow when the metrics are written. It follows changes
+	// of the namespace selection without generating the metrics again.
+	namespaceLabels func(namespace string) bool
+
+	// generateMetricsFunc generates metrics based on a given Kubernetes object
+	// and returns them grouped by metric family.
+	generateMetricsFunc func(interface{}) []metricsstore.FamilyStringer
+}
+
+type objectMetrics struct {
+	namespace string
+	// name and resourceVersion are only kept for debugging, see object.
+	name            string
+	resourceVersion string
+	families        []string
+}
+
+// NewMetricsStore returns a new MetricsStore.
+func NewMetricsStore(headers []string, generateFunc func(interface{}) []metricsstore.FamilyStringer) *MetricsStore {
+	return &MetricsStore{
+		generateMetricsFunc: generateFunc,
+		headers:             headers,
+		metrics:             map[types.UID]*objectMetrics{},
+	}
+}
+
+func (s *MetricsStore) generate(obj interface{}) (types.UID, *objectMetrics, error) {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return "", nil, err
+	}
+
+	families := s.generateMetricsFunc(obj)
+	familyStrings := make([]string, len(families))
+	for i, f := range families {
+		familyStrings[i] = f.String()
+	}
+
+	return o.GetUID(), &objectMetrics{
+		namespace:       o.GetNamespace(),
+		name:            o.GetName(),
+		resourceVersion: o.GetResourceVersion(),
+		families:        familyStrings,
+	}, nil
+}
+
+// Add generates the metrics of the given object and stores them.
+func (s *MetricsStore) Add(obj interface{}) error {
+	uid, m, err := s.generate(obj)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.metrics[uid] = m
+	s.lastEvent = time.Now()
+	s.countObjects()
+
+	return nil
+}
+
+// Update regenerates the metrics of the given object.
+func (s *MetricsStore) Update(obj interface{}) error {
+	return s.Add(obj)
+}
+
+// Delete removes the metrics of the given object.
+func (s *MetricsStore) Delete(obj interface{}) error {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.metrics, o.GetUID())
+	s.lastEvent = time.Now()
+	s.countObjects()
+
+	return nil
+}
+
+// List is not implemented, the store does not keep objects.
+func (s *MetricsStore) List() []interface{} {
+	return nil
+}
+
+// ListKeys is not implemented, the store does not keep objects.
+func (s *MetricsStore) ListKeys() []string {
+	return nil
+}
+
+// Get is not implemented, the store does not keep objects.
+func (s *MetricsStore) Get(obj interface{}) (item interface{}, exists bool, err error) {
+	return nil, false, nil
+}
+
+// GetByKey is not implemented, the store does not keep objects.
+func (s *MetricsStore) GetByKey(key string) (item interface{}, exists bool, err error) {
+	return nil, false, nil
+}
+
+// Replace deletes the content of the store and stores the metrics of the
+// given objects instead.
+func (s *MetricsStore) Replace(list []interface{}, _ string) error {
+	return s.replaceNamespace(nil, list)
+}
+
+// Resync is a no-op, there is nothing to resync.
+func (s *MetricsStore) Resync() error {
+	return nil
+}
+
+// replaceNamespace deletes the metrics of all objects in the given namespace
+// and stores the metrics of the given objects instead. A nil namespace
+// replaces the whole content of the store.
+func (s *MetricsStore) replaceNamespace(namespace *string, list []interface{}) error {
+	metrics := make(map[types.UID]*objectMetrics, len(list))
+	for _, obj := range list {
+		uid, m, err := s.generate(obj)
+		if err != nil {
+			return err
+		}
+		metrics[uid] = m
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	defer s.countObjects()
+
+	if namespace == nil {
+		s.metrics = metrics
+		return nil
+	}
+
+	for uid, m := range s.metrics {
+		if m.namespace == *namespace {
+			delete(s.metrics, uid)
+		}
+	}
+	for uid, m := range metrics {
+		s.metrics[uid] = m
+	}
+
+	return nil
+}
+
+// DeleteNamespace removes the metrics of all objects in the given namespace.
+func (s *MetricsStore) DeleteNamespace(namespace string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for uid, m := range s.metrics {
+		if m.namespace == namespace {
+			delete(s.metrics, uid)
+		}
+	}
+	s.countObjects()
+}
+
+// countObjects updates the objects gauge. s.mutex must be held.
+func (s *MetricsStore) countObjects() {
+	if s.objects != nil {
+		s.objects.Set(float64(len(s.metrics)))
+	}
+}
+
+// stats returns the number of objects in the store, the number of series
+// their metrics add up to and the time an object was last added, updated or
+// deleted.
+func (s *MetricsStore) stats() (objects int, series int, lastEvent time.Time) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, m := range s.metrics {
+		for _, f := range m.families {
+			series += strings.Count(f, "\n")
+		}
+	}
+	return len(s.metrics), series, s.lastEvent
+}
+
+// forNamespace returns a view on the store for a reflector which watches the
+// given namespace. Replacing the content of the view only replaces the objects
+// in that namespace. For metav1.NamespaceAll it replaces the whole store.
+func (s *MetricsStore) forNamespace(namespace string) cache.Store {
+	if namespace == "" {
+		return s
+	}
+	return &namespaceStore{MetricsStore: s, namespace: namespace}
+}
+
+type namespaceStore struct {
+	*MetricsStore
+	namespace string
+}
+
+func (s *namespaceStore) Replace(list []interface{}, _ string) error {
+	return s.replaceNamespace(&s.namespace, list)
+}
+
+// WriteAll writes all metrics of the store into the given writer, zipped with
+// the help text of each metric family.
+func (s *MetricsStore) WriteAll(w io.Writer) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for i, help := range s.headers {
+		w.Write([]byte(help))
+		w.Write([]byte{'\n'})
+		for _, m := range s.metrics {
+			s.writeFamily(w, m.families[i])
+		}
+	}
+}
+
+// writeFamily writes the series of a metric family of an object, without the
+// ones namespaceLabels drops.
+func (s *MetricsStore) writeFamily(w io.Writer, family string) {
+	if s.namespaceLabels == nil {
+		io.WriteString(w, family)
+		return
+	}
+
+	for family != "" {
+		series := family
+		if i := strings.IndexByte(family, '\n'); i >= 0 {
+			series = family[:i+1]
+		}
+		family = family[len(series):]
+		if ns, ok := namespaceLabel(series); ok && !s.namespaceLabels(ns) {
+			continue
+		}
+		io.WriteString(w, series)
+	}
+}
+
+// namespaceLabel returns the value of the namespace label of a series in the
+// text format.
+func namespaceLabel(series string) (string, bool) {
+	const label = `namespace="`
+	for start := 0; ; {
+		i := strings.Index(series[start:], label)
+		if i < 0 {
+			return "", false
+		}
+		i += start
+		if i > 0 && (series[i-1] == '{' || series[i-1] == ',') {
+			value := series[i+len(label):]
+			end := strings.IndexByte(value, '"')
+			if end < 0 {
+				return "", false
+			}
+			return value[:end], true
+		}
+		start = i + 1
+	}
+}
+
+// WriteNamespaces is like WriteAll, but only writes the metrics of objects in
+// the allowed namespaces. The metrics of namespaced objects all carry the
+// namespace of the object as their namespace label. Cluster-scoped objects are
+// skipped.
+func (s *MetricsStore) WriteNamespaces(w io.Writer, allowed func(namespace string) bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	visible := make([]*objectMetrics, 0, len(s.metrics))
+	for _, m := range s.metrics {
+		if m.namespace != "" && allowed(m.namespace) {
+			visible = append(visible, m)
+		}
+	}
+
+	for i, help := range s.headers {
+		w.Write([]byte(help))
+		w.Write([]byte{'\n'})
+		for _, m := range visible {
+			w.Write([]byte(m.families[i]))
+		}
+	}
+}
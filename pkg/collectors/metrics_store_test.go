@@ -0,0 +1,185 @@
+package collectors
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kube-state-metrics/pkg/metric"
+	metricsstore "k8s.io/kube-state-metrics/pkg/metrics_store"
+
+	quotav1 "github.com/openshift/api/quota/v1"
+	routev1 "github.com/openshift/api/route/v1"
+)
+
+func newTestRoute(namespace, name string) *routev1.Route {
+	weight := int32(100)
+	return &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: types.UID(namespace + "/" + name)},
+		Spec: routev1.RouteSpec{
+			To: routev1.RouteTargetReference{Kind: "Service", Name: name, Weight: &weight},
+		},
+	}
+}
+
+func newRouteMetricsStore() *MetricsStore {
+	families := metric.FilterMetricFamilies(allowAll{}, routeMetricFamilies)
+	return NewMetricsStore(metric.ExtractMetricFamilyHeaders(families), metric.ComposeMetricGenFuncs(families))
+}
+
+type allowAll struct{}
+
+func (allowAll) IsIncluded(string) bool { return true }
+func (allowAll) IsExcluded(string) bool { return false }
+
+func writeStore(s *MetricsStore) string {
+	buf := &bytes.Buffer{}
+	s.WriteAll(buf)
+	return buf.String()
+}
+
+func TestMetricsStoreReplaceNamespace(t *testing.T) {
+	s := newRouteMetricsStore()
+	a, b := s.forNamespace("a"), s.forNamespace("b")
+
+	if err := a.Replace([]interface{}{newTestRoute("a", "r1"), newTestRoute("a", "r2")}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Replace([]interface{}{newTestRoute("b", "r1")}, ""); err != nil {
+		t.Fatal(err)
+	}
+	// A relist in namespace a must not touch namespace b.
+	if err := a.Replace([]interface{}{newTestRoute("a", "r2")}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	out := writeStore(s)
+	for _, want := range []string{`namespace="a",route="r2"`, `namespace="b",route="r1"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %s, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `namespace="a",route="r1"`) {
+		t.Errorf("expected route a/r1 to be replaced, got:\n%s", out)
+	}
+
+	s.DeleteNamespace("a")
+	out = writeStore(s)
+	if strings.Contains(out, `namespace="a"`) || !strings.Contains(out, `namespace="b"`) {
+		t.Errorf("expected only namespace a to be deleted, got:\n%s", out)
+	}
+
+	// The store itself replaces everything.
+	if err := s.Replace([]interface{}{newTestRoute("c", "r1")}, ""); err != nil {
+		t.Fatal(err)
+	}
+	out = writeStore(s)
+	if strings.Contains(out, `namespace="b"`) || !strings.Contains(out, `namespace="c"`) {
+		t.Errorf("expected the whole store to be replaced, got:\n%s", out)
+	}
+}
+
+func TestMetricsStoreWriteNamespaces(t *testing.T) {
+	s := newRouteMetricsStore()
+	if err := s.Replace([]interface{}{newTestRoute("a", "r1"), newTestRoute("b", "r1"), newTestRoute("c", "r1")}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	s.WriteNamespaces(buf, func(ns string) bool { return ns == "a" || ns == "c" })
+	out := buf.String()
+	for _, want := range []string{`# TYPE openshift_route_info gauge`, `namespace="a",route="r1"`, `namespace="c",route="r1"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %s, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `namespace="b"`) {
+		t.Errorf("expected namespace b to be filtered, got:\n%s", out)
+	}
+}
+
+func TestMetricsStoreNamespaceLabels(t *testing.T) {
+	s := NewMetricsStore([]string{"# HELP quota_usage Usage.\n# TYPE quota_usage gauge"}, func(interface{}) []metricsstore.FamilyStringer {
+		return []metricsstore.FamilyStringer{metric.Family{
+			Name: "quota_usage",
+			Metrics: []*metric.Metric{
+				{LabelKeys: []string{"name", "namespace"}, LabelValues: []string{"q1", "a"}, Value: 1},
+				{LabelKeys: []string{"name", "namespace"}, LabelValues: []string{"q1", "b"}, Value: 2},
+				{LabelKeys: []string{"name", "label_namespace"}, LabelValues: []string{"q1", "b"}, Value: 3},
+				{LabelKeys: []string{"name"}, LabelValues: []string{"q1"}, Value: 4},
+			},
+		}}
+	})
+	s.namespaceLabels = func(ns string) bool { return ns == "a" }
+	if err := s.Add(&quotav1.ClusterResourceQuota{ObjectMeta: metav1.ObjectMeta{Name: "q1", UID: "q1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP quota_usage Usage.
+# TYPE quota_usage gauge
+quota_usage{name="q1",namespace="a"} 1
+quota_usage{name="q1",label_namespace="b"} 3
+quota_usage{name="q1"} 4
+`
+	if out := writeStore(s); out != want {
+		t.Errorf("expected the series of namespace b to be dropped, got:\n%s", out)
+	}
+}
+
+func TestMetricsStoreStats(t *testing.T) {
+	s := newRouteMetricsStore()
+	if err := s.Replace([]interface{}{newTestRoute("a", "r1"), newTestRoute("a", "r2")}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	objects, series, lastEvent := s.stats()
+	if objects != 2 || !lastEvent.IsZero() {
+		t.Errorf("expected 2 objects and no watch event, got %d objects and last event %s", objects, lastEvent)
+	}
+	if want := strings.Count(writeStore(s), "\n") - 2*len(s.headers); series != want {
+		t.Errorf("expected %d series, got %d", want, series)
+	}
+
+	if err := s.Delete(newTestRoute("a", "r1")); err != nil {
+		t.Fatal(err)
+	}
+	if objects, _, lastEvent = s.stats(); objects != 1 || lastEvent.IsZero() {
+		t.Errorf("expected 1 object and the time of the delete, got %d objects and last event %s", objects, lastEvent)
+	}
+}
+
+func TestMetricsStoreObject(t *testing.T) {
+	s := newRouteMetricsStore()
+	route := newTestRoute("a", "r1")
+	route.ResourceVersion = "42"
+	route.Spec.Host = "example.com"
+	if err := s.Replace([]interface{}{route, newTestRoute("b", "r1")}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	d, ok, err := s.object("a", "r1")
+	if err != nil || !ok {
+		t.Fatalf("expected route a/r1 to be found, got %t, %v", ok, err)
+	}
+	if d.Key != "a/r1" || d.UID != route.UID || d.ResourceVersion != "42" {
+		t.Errorf("expected key a/r1 at resourceVersion 42, got %+v", d)
+	}
+	var info *FamilyDetails
+	for i := range d.Families {
+		if d.Families[i].Name == "openshift_route_info" {
+			info = &d.Families[i]
+		}
+	}
+	if info == nil || info.Type != "gauge" || len(info.Metrics) != 1 {
+		t.Fatalf("expected a single openshift_route_info gauge, got %+v", d.Families)
+	}
+	if m := info.Metrics[0]; m.Value != "1" || m.Labels["namespace"] != "a" || m.Labels["route"] != "r1" || m.Labels["host"] != "example.com" {
+		t.Errorf("expected the info series of route a/r1, got %+v", m)
+	}
+
+	if _, ok, err := s.object("c", "r1"); ok || err != nil {
+		t.Errorf("expected route c/r1 not to be found, got %t, %v", ok, err)
+	}
+}
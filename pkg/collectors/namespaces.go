@@ -0,0 +1,295 @@
+package collectors
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// namespaceSelection keeps track of the namespaces whose objects are exposed.
+// They are given by a static list of namespaces, all namespaces matching a
+// label selector, or both, minus a denylist.
+//
+// With a label selector, the selection changes as namespaces are created,
+// deleted and relabeled. Namespaced collectors then get a reflector for every
+// namespace entering the selection and lose the reflector, along with its
+// metrics, of every namespace leaving it. Cluster-scoped collectors keep
+// watching all objects and drop the series of unselected namespaces when their
+// metrics are written, see MetricsStore.namespaceLabels.
+type namespaceSelection struct {
+	// allowed is nil if all namespaces are allowed.
+	allowed  map[string]struct{}
+	denied   map[string]struct{}
+	selector labels.Selector
+
+	mu       sync.RWMutex
+	selected map[string]struct{}
+	// namespaced collectors get a reflector per selected namespace.
+	namespaced []*collectorReflectors
+}
+
+func newNamespaceSelection(namespaces []string, denylist []string, selector labels.Selector) *namespaceSelection {
+	s := &namespaceSelection{
+		denied:   map[string]struct{}{},
+		selector: selector,
+		selected: map[string]struct{}{},
+	}
+	for _, ns := range namespaces {
+		if ns == metav1.NamespaceAll {
+			s.allowed = nil
+			break
+		}
+		if s.allowed == nil {
+			s.allowed = map[string]struct{}{}
+		}
+		s.allowed[ns] = struct{}{}
+	}
+	for _, ns := range denylist {
+		s.denied[ns] = struct{}{}
+	}
+
+	if !s.dynamic() {
+		for ns := range s.allowed {
+			if s.matches(ns) {
+				s.selected[ns] = struct{}{}
+			}
+		}
+	}
+
+	return s
+}
+
+// dynamic tells whether the selection follows the namespaces in the cluster.
+func (s *namespaceSelection) dynamic() bool {
+	return s.selector != nil && !s.selector.Empty()
+}
+
+// static tells whether the selection is a fixed list of namespaces.
+func (s *namespaceSelection) static() bool {
+	return !s.dynamic() && s.allowed != nil
+}
+
+// restricted tells whether some namespaces are not exposed.
+func (s *namespaceSelection) restricted() bool {
+	return s.allowed != nil || len(s.denied) > 0 || s.dynamic()
+}
+
+// matches tells whether the namespace with the given name passes the static
+// namespace list and the denylist.
+func (s *namespaceSelection) matches(ns string) bool {
+	if _, ok := s.denied[ns]; ok {
+		return false
+	}
+	if s.allowed == nil {
+		return true
+	}
+	_, ok := s.allowed[ns]
+	return ok
+}
+
+// has tells whether the objects of the given namespace are exposed.
+func (s *namespaceSelection) has(ns string) bool {
+	if !s.dynamic() && s.allowed == nil {
+		return s.matches(ns)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.selected[ns]
+	return ok
+}
+
+// namespaces returns the namespaces a namespaced collector needs reflectors
+// for right now.
+func (s *namespaceSelection) namespaces() []string {
+	if !s.dynamic() && s.allowed == nil {
+		// A single reflector for all namespaces, the denied namespaces are
+		// excluded through deniedFieldSelector.
+		return []string{metav1.NamespaceAll}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.selectedNamespaces()
+}
+
+// selectedNamespaces returns the selected namespaces. s.mu must be held.
+func (s *namespaceSelection) selectedNamespaces() []string {
+	namespaces := make([]string, 0, len(s.selected))
+	for ns := range s.selected {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// deniedFieldSelector returns a field selector which excludes the objects of
+// all denied namespaces.
+func (s *namespaceSelection) deniedFieldSelector() string {
+	selectors := make([]fields.Selector, 0, len(s.denied))
+	for ns := range s.denied {
+		selectors = append(selectors, fields.OneTermNotEqualSelector("metadata.namespace", ns))
+	}
+	return fields.AndSelectors(selectors...).String()
+}
+
+// register adds the reflectors of a collector to the selection.
+func (s *namespaceSelection) register(r *collectorReflectors, scope collectorScope) {
+	if scope == clusterScoped || (!s.dynamic() && s.allowed == nil) {
+		r.addNamespace(metav1.NamespaceAll)
+		return
+	}
+
+	// Hold the lock, so that no namespace enters or leaves the selection
+	// before the collector is registered.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ns := range s.selectedNamespaces() {
+		r.addNamespace(ns)
+	}
+	s.namespaced = append(s.namespaced, r)
+}
+
+// unregister removes the reflectors of a collector from the selection.
+func (s *namespaceSelection) unregister(r *collectorReflectors) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.namespaced = withoutReflectors(s.namespaced, r)
+}
+
+// withoutReflectors returns a copy of list without r. The list is copied, as
+// add and remove iterate over it without holding the lock.
+func withoutReflectors(list []*collectorReflectors, r *collectorReflectors) []*collectorReflectors {
+	filtered := make([]*collectorReflectors, 0, len(list))
+	for _, l := range list {
+		if l != r {
+			filtered = append(filtered, l)
+		}
+	}
+	return filtered
+}
+
+// takeOver moves the namespaced collectors of a previous static selection
+// over to s, which must be static too. Their reflectors are started and
+// stopped for the namespaces which entered and left the selection.
+func (s *namespaceSelection) takeOver(previous *namespaceSelection) {
+	previous.mu.Lock()
+	namespaced := previous.namespaced
+	previous.namespaced = nil
+	previous.mu.Unlock()
+
+	s.mu.Lock()
+	s.namespaced = append(s.namespaced, namespaced...)
+	s.mu.Unlock()
+
+	for ns := range previous.selected {
+		if _, ok := s.selected[ns]; !ok {
+			klog.Infof("namespace %s left the namespace selection", ns)
+			for _, r := range namespaced {
+				r.removeNamespace(ns)
+			}
+		}
+	}
+	for ns := range s.selected {
+		if _, ok := previous.selected[ns]; !ok {
+			klog.Infof("namespace %s entered the namespace selection", ns)
+			for _, r := range namespaced {
+				r.addNamespace(ns)
+			}
+		}
+	}
+}
+
+func (s *namespaceSelection) update(ns *corev1.Namespace) {
+	if ns.DeletionTimestamp.IsZero() && s.matches(ns.Name) && s.selector.Matches(labels.Set(ns.Labels)) {
+		s.add(ns.Name)
+		return
+	}
+	s.remove(ns.Name)
+}
+
+func (s *namespaceSelection) add(ns string) {
+	s.mu.Lock()
+	if _, ok := s.selected[ns]; ok {
+		s.mu.Unlock()
+		return
+	}
+	s.selected[ns] = struct{}{}
+	namespaced := s.namespaced
+	s.mu.Unlock()
+
+	klog.Infof("namespace %s entered the namespace selection", ns)
+	for _, r := range namespaced {
+		r.addNamespace(ns)
+	}
+}
+
+func (s *namespaceSelection) remove(ns string) {
+	s.mu.Lock()
+	if _, ok := s.selected[ns]; !ok {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.selected, ns)
+	namespaced := s.namespaced
+	s.mu.Unlock()
+
+	klog.Infof("namespace %s left the namespace selection", ns)
+	for _, r := range namespaced {
+		r.removeNamespace(ns)
+	}
+}
+
+// watch keeps the selection up to date with the namespaces in the cluster
+// until ctx is done. It returns once the initial list of namespaces has been
+// processed.
+func (s *namespaceSelection) watch(ctx context.Context, client kubernetes.Interface) {
+	selector := s.selector.String()
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.LabelSelector = selector
+			return client.CoreV1().Namespaces().List(context.TODO(), opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.LabelSelector = selector
+			return client.CoreV1().Namespaces().Watch(context.TODO(), opts)
+		},
+	}
+
+	informer := cache.NewSharedIndexInformer(lw, &corev1.Namespace{}, 0, cache.Indexers{})
+	registration, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { s.update(obj.(*corev1.Namespace)) },
+		UpdateFunc: func(_, obj interface{}) { s.update(obj.(*corev1.Namespace)) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if ns, ok := obj.(*corev1.Namespace); ok {
+				s.remove(ns.Name)
+			}
+		},
+	})
+	if err != nil {
+		klog.Fatalf("cannot watch namespaces: %v", err)
+	}
+	go informer.Run(ctx.Done())
+
+	klog.Infof("Waiting for namespaces matching %q", selector)
+	if !cache.WaitForCacheSync(ctx.Done(), registration.HasSynced) {
+		return
+	}
+	klog.Infof("Using namespaces matching %q: %s", selector, strings.Join(s.namespaces(), ","))
+}
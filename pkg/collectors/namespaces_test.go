@@ -0,0 +1,153 @@
+package collectors
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/kube-state-metrics/pkg/options"
+
+	quotav1 "github.com/openshift/api/quota/v1"
+	quotafake "github.com/openshift/client-go/quota/clientset/versioned/fake"
+	routefake "github.com/openshift/client-go/route/clientset/versioned/fake"
+)
+
+func newTestNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestBuilderNamespaceSelector(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	team := map[string]string{"team": "a"}
+	clients := newFakeClientFactory()
+	clients.kube = kubefake.NewSimpleClientset(
+		newTestNamespace("ns1", team),
+		newTestNamespace("ns2", nil),
+		newTestNamespace("denied", team),
+	)
+	clients.route = routefake.NewSimpleClientset(
+		newTestRoute("ns1", "r1"),
+		newTestRoute("ns2", "r2"),
+		newTestRoute("denied", "r3"),
+	)
+
+	collectors := newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"routes"}).
+		WithNamespaceSelector(labels.SelectorFromSet(team)).
+		WithNamespaceDenylist([]string{"denied"}).
+		Build()
+
+	out := waitForOutput(t, collectors, `openshift_route_labels{namespace="ns1",route="r1"}`)
+	if strings.Contains(out, `namespace="ns2"`) || strings.Contains(out, `namespace="denied"`) {
+		t.Errorf("expected only namespace ns1 to be exposed, got:\n%s", out)
+	}
+
+	// ns2 enters the selection, ns1 leaves it.
+	if _, err := clients.kube.CoreV1().Namespaces().Update(ctx, newTestNamespace("ns2", team), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clients.kube.CoreV1().Namespaces().Update(ctx, newTestNamespace("ns1", nil), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForOutput(t, collectors, `openshift_route_labels{namespace="ns2",route="r2"}`)
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return !strings.Contains(collect(collectors), `namespace="ns1"`), nil
+	})
+	if err != nil {
+		t.Errorf("expected the metrics of namespace ns1 to be dropped, got:\n%s", collect(collectors))
+	}
+}
+
+func TestBuilderNamespaceSelectorClusterScoped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	team := map[string]string{"team": "a"}
+	clients := newFakeClientFactory()
+	clients.kube = kubefake.NewSimpleClientset(newTestNamespace("ns1", team), newTestNamespace("ns2", nil))
+	usage := func(ns string) quotav1.ResourceQuotaStatusByNamespace {
+		return quotav1.ResourceQuotaStatusByNamespace{
+			Namespace: ns,
+			Status:    corev1.ResourceQuotaStatus{Used: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")}},
+		}
+	}
+	clients.quota = quotafake.NewSimpleClientset(&quotav1.ClusterResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "quota1", UID: "quota1"},
+		Status: quotav1.ClusterResourceQuotaStatus{
+			Namespaces: quotav1.ResourceQuotasStatusByNamespace{usage("ns1"), usage("ns2")},
+		},
+	})
+
+	collectors := newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"clusterresourcequotas"}).
+		WithNamespaceSelector(labels.SelectorFromSet(team)).
+		Build()
+	out := waitForOutput(t, collectors, `openshift_clusterresourcequota_namespace_usage{name="quota1",namespace="ns1"`)
+	if strings.Contains(out, `namespace="ns2"`) {
+		t.Errorf("expected only namespace ns1 to be exposed, got:\n%s", out)
+	}
+
+	// ns2 enters the selection, without listing the quotas again.
+	if _, err := clients.kube.CoreV1().Namespaces().Update(ctx, newTestNamespace("ns2", team), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForOutput(t, collectors, `openshift_clusterresourcequota_namespace_usage{name="quota1",namespace="ns2"`)
+	lists := 0
+	for _, a := range clients.quota.Actions() {
+		if a.GetVerb() == "list" {
+			lists++
+		}
+	}
+	if lists != 1 {
+		t.Errorf("expected the quotas to be listed once, got %d lists", lists)
+	}
+}
+
+func TestBuilderNamespaceDenylist(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clients := newFakeClientFactory()
+	collectors := newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"routes"}).
+		WithNamespaceDenylist([]string{"a", "b"}).
+		Build()
+	waitForOutput(t, collectors)
+
+	for _, a := range clients.route.Actions() {
+		if a.GetVerb() != "list" {
+			continue
+		}
+		if ns := a.GetNamespace(); ns != metav1.NamespaceAll {
+			t.Errorf("expected a single reflector for all namespaces, got one for %q", ns)
+		}
+		fields := a.(clienttesting.ListAction).GetListRestrictions().Fields.String()
+		if fields != "metadata.namespace!=a,metadata.namespace!=b" && fields != "metadata.namespace!=b,metadata.namespace!=a" {
+			t.Errorf("expected the denied namespaces to be excluded by a field selector, got %q", fields)
+		}
+	}
+
+	collectors = newTestBuilder(t, ctx, clients).
+		WithEnabledCollectors([]string{"routes"}).
+		WithNamespaces(options.NamespaceList{"a", "c"}).
+		WithNamespaceDenylist([]string{"a"}).
+		Build()
+	waitForOutput(t, collectors)
+
+	for _, a := range clients.route.Actions() {
+		if a.GetVerb() == "list" && a.GetNamespace() == "a" {
+			t.Error("expected no reflector for denied namespace a")
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ErrUnknownCollector is returned for collectors not built by the Builder.
+var ErrUnknownCollector = errors.New("unknown collector")
+
+// ObjectDetails are the metrics a collector generated for a single object
+// when it last processed it.
+type ObjectDetails struct {
+	// Key is namespace/name for namespaced objects and name otherwise.
+	Key             string          `json:"key"`
+	UID             types.UID       `json:"uid"`
+	ResourceVersion string          `json:"resourceVersion"`
+	Families        []FamilyDetails `json:"families"`
+}
+
+// FamilyDetails are the metrics of a single metric family.
+type FamilyDetails struct {
+	Name    string          `json:"name"`
+	Type    string          `json:"type"`
+	Metrics []MetricDetails `json:"metrics"`
+}
+
+// MetricDetails is a single series. Like in the Prometheus HTTP API, the
+// value is a string, so that NaN and infinite values can be represented.
+type MetricDetails struct {
+	Labels map[string]string `json:"labels"`
+	Value  string            `json:"value"`
+}
+
+// Object returns the metrics the given collector generated for the object
+// with the given namespace and name. It returns false if the collector does
+// not hold such an object and an error if there is no such collector.
+func (b *Builder) Object(collector string, namespace string, name string) (ObjectDetails, bool, error) {
+	b.mu.RLock()
+	c, ok := b.built[collector]
+	b.mu.RUnlock()
+	if !ok {
+		return ObjectDetails{}, false, fmt.Errorf("%w %q", ErrUnknownCollector, collector)
+	}
+
+	return c.reflectors.store.object(namespace, name)
+}
+
+// object returns the metrics of the object with the given namespace and
+// name. It walks the whole store.
+func (s *MetricsStore) object(namespace string, name string) (ObjectDetails, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for uid, m := range s.metrics {
+		if m.namespace != namespace || m.name != name {
+			continue
+		}
+
+		d := ObjectDetails{Key: name, UID: uid, ResourceVersion: m.resourceVersion, Families: []FamilyDetails{}}
+		if namespace != "" {
+			d.Key = namespace + "/" + name
+		}
+		for i, header := range s.headers {
+			text := &strings.Builder{}
+			text.WriteString(header + "\n")
+			s.writeFamily(text, m.families[i])
+			f, ok, err := parseFamily(text.String())
+			if err != nil {
+				return ObjectDetails{}, false, err
+			}
+			if ok {
+				d.Families = append(d.Families, f)
+			}
+		}
+		return d, true, nil
+	}
+
+	return ObjectDetails{}, false, nil
+}
+
+// parseFamily parses a single metric family in the text format. It returns
+// false if the family has no metrics.
+func parseFamily(text string) (FamilyDetails, bool, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(text))
+	if err != nil {
+		return FamilyDetails{}, false, fmt.Errorf("cannot parse generated metrics: %w", err)
+	}
+	if len(families) > 1 {
+		return FamilyDetails{}, false, fmt.Errorf("expected a single metric family, got %d", len(families))
+	}
+
+	var d FamilyDetails
+	for name, f := range families {
+		d = FamilyDetails{Name: name, Type: strings.ToLower(f.GetType().String()), Metrics: []MetricDetails{}}
+		for _, m := range f.GetMetric() {
+			labels := make(map[string]string, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			d.Metrics = append(d.Metrics, MetricDetails{Labels: labels, Value: strconv.FormatFloat(sampleValue(m), 'g', -1, 64)})
+		}
+	}
+
+	return d, len(families) == 1, nil
+}
@@ -0,0 +1,240 @@
+package collectors
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// PrometheusCollector exposes the metrics of a MetricsStore as a
+// prometheus.Collector, so that programs embedding openshift-state-metrics can
+// register them with their own prometheus.Registry next to other metrics.
+//
+// Every metric family is described, so that registries reject collectors
+// exposing the same metrics twice. Families whose label names are known
+// up front are described with their labels and checked against them. The
+// labels of families like openshift_route_labels depend on the objects, these
+// families are described by name only. The pre-rendered metrics of every
+// object are parsed once and reused until the object changes, which still
+// costs more than writing them out like collector.Collector does.
+type PrometheusCollector struct {
+	// store returns the store to collect, nil if there is none.
+	store func() *MetricsStore
+	// families are the descriptors of the families of the store, keyed by
+	// name.
+	families map[string]familyDesc
+
+	mu sync.Mutex
+	// parsed holds the parsed metrics of the objects of the last collect.
+	// The store replaces the metrics of an object when it changes, so they
+	// are keyed by their pointer.
+	parsed map[*objectMetrics][]parsedSeries
+}
+
+// familyDesc describes a metric family.
+type familyDesc struct {
+	desc *prometheus.Desc
+	// labelNames are the label names of the family in the order of the
+	// descriptor, nil if they depend on the objects.
+	labelNames []string
+}
+
+// parsedSeries is a series parsed from the text format.
+type parsedSeries struct {
+	metric prometheus.Metric
+	// namespace is the value of the namespace label, if hasNamespace.
+	namespace    string
+	hasNamespace bool
+}
+
+// NewPrometheusCollector returns a new PrometheusCollector for the given
+// store. Its families are described by name only.
+func NewPrometheusCollector(store *MetricsStore) *PrometheusCollector {
+	return newPrometheusCollector(func() *MetricsStore { return store }, store.headers, nil)
+}
+
+// newPrometheusCollector returns a new PrometheusCollector describing the
+// families of the given headers, with the given label names if they are
+// known.
+func newPrometheusCollector(store func() *MetricsStore, headers []string, labelNames map[string][]string) *PrometheusCollector {
+	families := make(map[string]familyDesc, len(headers))
+	for _, header := range headers {
+		name, help := parseHeader(header)
+		names, ok := labelNames[name]
+		if ok && names == nil {
+			names = []string{}
+		}
+		families[name] = familyDesc{desc: prometheus.NewDesc(name, help, names, nil), labelNames: names}
+	}
+	return &PrometheusCollector{store: store, families: families, parsed: map[*objectMetrics][]parsedSeries{}}
+}
+
+// Describe implements prometheus.Collector.
+func (c *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, f := range c.families {
+		ch <- f.desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.store()
+	if s == nil {
+		return
+	}
+
+	// The metrics of an object are never changed in place, so they can be
+	// parsed without holding the lock of the store.
+	s.mutex.RLock()
+	objects := make([]*objectMetrics, 0, len(s.metrics))
+	for _, m := range s.metrics {
+		objects = append(objects, m)
+	}
+	s.mutex.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	parsed := make(map[*objectMetrics][]parsedSeries, len(objects))
+	for _, m := range objects {
+		series, ok := c.parsed[m]
+		if !ok {
+			series = parseObjectMetrics(s.headers, m, c.families)
+		}
+		parsed[m] = series
+		for _, p := range series {
+			if p.hasNamespace && s.namespaceLabels != nil && !s.namespaceLabels(p.namespace) {
+				continue
+			}
+			ch <- p.metric
+		}
+	}
+	c.parsed = parsed
+}
+
+// parseHeader returns the name and help of a family from its header, which
+// starts with "# HELP <name> <help>", see metric.ExtractMetricFamilyHeaders.
+func parseHeader(header string) (name, help string) {
+	help, _, _ = strings.Cut(strings.TrimPrefix(header, "# HELP "), "\n")
+	name, help, _ = strings.Cut(help, " ")
+	return name, help
+}
+
+// parseObjectMetrics parses the metrics of an object into const metrics. The
+// series of families with known label names use their descriptor.
+func parseObjectMetrics(headers []string, m *objectMetrics, families map[string]familyDesc) []parsedSeries {
+	var series []parsedSeries
+	for i, header := range headers {
+		if m.families[i] == "" {
+			continue
+		}
+
+		name, help := parseHeader(header)
+		var parser expfmt.TextParser
+		parsedFamilies, err := parser.TextToMetricFamilies(strings.NewReader(header + "\n" + m.families[i]))
+		if err != nil {
+			desc := prometheus.NewDesc(name, help, nil, nil)
+			series = append(series, parsedSeries{metric: prometheus.NewInvalidMetric(desc, err)})
+			continue
+		}
+
+		for _, f := range parsedFamilies {
+			valueType := prometheus.UntypedValue
+			switch f.GetType() {
+			case dto.MetricType_GAUGE:
+				valueType = prometheus.GaugeValue
+			case dto.MetricType_COUNTER:
+				valueType = prometheus.CounterValue
+			}
+
+			described, known := families[f.GetName()]
+			known = known && described.labelNames != nil
+			for _, metric := range f.GetMetric() {
+				var p parsedSeries
+				labels := make(map[string]string, len(metric.GetLabel()))
+				labelNames := make([]string, len(metric.GetLabel()))
+				labelValues := make([]string, len(metric.GetLabel()))
+				for i, l := range metric.GetLabel() {
+					labels[l.GetName()] = l.GetValue()
+					labelNames[i], labelValues[i] = l.GetName(), l.GetValue()
+					if l.GetName() == "namespace" {
+						p.namespace, p.hasNamespace = l.GetValue(), true
+					}
+				}
+
+				var err error
+				desc := prometheus.NewDesc(f.GetName(), help, labelNames, nil)
+				if known {
+					desc = described.desc
+					labelValues, err = describedLabelValues(described.labelNames, labels)
+				}
+				if err == nil {
+					p.metric, err = prometheus.NewConstMetric(desc, valueType, sampleValue(metric), labelValues...)
+				}
+				if err != nil {
+					p.metric = prometheus.NewInvalidMetric(desc, err)
+				}
+				series = append(series, p)
+			}
+		}
+	}
+	return series
+}
+
+// describedLabelValues returns the values of the given labels in the order of
+// the label names of a descriptor. The labels must match these names.
+func describedLabelValues(names []string, labels map[string]string) ([]string, error) {
+	if len(names) != len(labels) {
+		return nil, fmt.Errorf("labels %v do not match the labels %v of the descriptor", labels, names)
+	}
+	values := make([]string, len(names))
+	for i, name := range names {
+		v, ok := labels[name]
+		if !ok {
+			return nil, fmt.Errorf("labels %v do not match the labels %v of the descriptor", labels, names)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// sampleValue returns the value of a gauge, counter or untyped sample.
+func sampleValue(m *dto.Metric) float64 {
+	switch {
+	case m.GetGauge() != nil:
+		return m.GetGauge().GetValue()
+	case m.GetCounter() != nil:
+		return m.GetCounter().GetValue()
+	}
+	return m.GetUntyped().GetValue()
+}
+
+// PrometheusCollectors returns the collectors built by the last call to Build
+// as prometheus.Collectors, in the same order as CollectorNames. They follow
+// later calls to Build: a rebuilt collector exposes the metrics of its new
+// store and a disabled one exposes nothing. Collectors enabled by a later
+// Build are only part of the result of a new call, and they only describe the
+// families enabled when they are returned.
+func (b *Builder) PrometheusCollectors() []prometheus.Collector {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	collectors := make([]prometheus.Collector, len(b.collectorNames))
+	for i, name := range b.collectorNames {
+		name := name
+		c := b.built[name]
+		collectors[i] = newPrometheusCollector(func() *MetricsStore {
+			b.mu.RLock()
+			defer b.mu.RUnlock()
+			if c, ok := b.built[name]; ok {
+				return c.reflectors.store
+			}
+			return nil
+		}, c.reflectors.store.headers, c.labelNames)
+	}
+	return collectors
+}
@@ -0,0 +1,186 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "github.com/openshift/api/apps/v1"
+	buildv1 "github.com/openshift/api/build/v1"
+	quotav1 "github.com/openshift/api/quota/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	userv1 "github.com/openshift/api/user/v1"
+	routefake "github.com/openshift/client-go/route/clientset/versioned/fake"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+func TestPrometheusCollector(t *testing.T) {
+	s := newRouteMetricsStore()
+	route := newTestRoute("a", "r1")
+	route.Spec.Host = "example.com"
+	if err := s.Replace([]interface{}{route}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewPrometheusCollector(s)
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(c); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `
+# HELP openshift_route_info Information about route.
+# TYPE openshift_route_info gauge
+openshift_route_info{host="example.com",namespace="a",path="",route="r1",tls_termination="",to_kind="Service",to_name="r1",to_weight="100"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want), "openshift_route_info"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(c); n != strings.Count(writeStore(s), "\n")-2*len(s.headers) {
+		t.Errorf("expected every series of the store to be collected, got %d", n)
+	}
+
+	// Changed objects are parsed again.
+	route = route.DeepCopy()
+	route.Spec.Host = "example.org"
+	if err := s.Update(route); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(strings.Replace(want, "example.com", "example.org", 1)), "openshift_route_info"); err != nil {
+		t.Error(err)
+	}
+
+	// The namespace filter of the store applies.
+	s.namespaceLabels = func(ns string) bool { return ns != "a" }
+	if n := testutil.CollectAndCount(c); n != 0 {
+		t.Errorf("expected the series of namespace a to be dropped, got %d", n)
+	}
+}
+
+func TestCollectorLabelNames(t *testing.T) {
+	created := metav1.Unix(1500000000, 0)
+	one := intstr.FromInt(1)
+	quantities := corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")}
+	route := newTestRoute("ns1", "r1")
+	route.CreationTimestamp = created
+	route.Spec.TLS = &routev1.TLSConfig{Termination: routev1.TLSTerminationEdge}
+	route.Status.Ingress = []routev1.RouteIngress{{
+		Host:       "example.com",
+		RouterName: "default",
+		Conditions: []routev1.RouteIngressCondition{{Type: routev1.RouteAdmitted, Status: corev1.ConditionTrue}},
+	}}
+	objects := map[string]interface{}{
+		"deploymentConfigs": &appsv1.DeploymentConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "dc1", Namespace: "ns1", CreationTimestamp: created},
+			Spec: appsv1.DeploymentConfigSpec{Strategy: appsv1.DeploymentStrategy{
+				RollingParams: &appsv1.RollingDeploymentStrategyParams{MaxUnavailable: &one, MaxSurge: &one},
+			}},
+		},
+		"buildconfigs": &buildv1.BuildConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "bc1", Namespace: "ns1", CreationTimestamp: created},
+		},
+		"builds": &buildv1.Build{
+			ObjectMeta: metav1.ObjectMeta{Name: "b1", Namespace: "ns1", CreationTimestamp: created},
+			Status: buildv1.BuildStatus{
+				StartTimestamp:      &created,
+				CompletionTimestamp: &created,
+				Duration:            time.Minute,
+			},
+		},
+		"clusterresourcequotas": &quotav1.ClusterResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "q1", CreationTimestamp: created},
+			Spec:       quotav1.ClusterResourceQuotaSpec{Quota: corev1.ResourceQuotaSpec{Hard: quantities}},
+			Status: quotav1.ClusterResourceQuotaStatus{
+				Total: corev1.ResourceQuotaStatus{Used: quantities},
+				Namespaces: quotav1.ResourceQuotasStatusByNamespace{
+					{Namespace: "ns1", Status: corev1.ResourceQuotaStatus{Hard: quantities, Used: quantities}},
+				},
+			},
+		},
+		"routes": route,
+		"groups": &userv1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "g1", CreationTimestamp: created},
+			Users:      userv1.OptionalNames{"user1"},
+		},
+	}
+
+	for _, name := range AvailableCollectors() {
+		spec, _ := lookupCollector(name)
+		checkLabelNames(t, name, spec, objects[name])
+	}
+
+	var config CustomResourceStateConfig
+	if err := yaml.UnmarshalStrict([]byte(widgetConfig), &config); err != nil {
+		t.Fatal(err)
+	}
+	r := config.Resources[0]
+	checkLabelNames(t, r.collectorName(), r.collectorSpec(), newTestWidget("ns1", "widget1"))
+}
+
+// checkLabelNames checks that the metrics generated for obj have the label
+// names declared for their family, and that every such family generates a
+// metric.
+func checkLabelNames(t *testing.T, name string, spec collectorSpec, obj interface{}) {
+	t.Helper()
+
+	if obj == nil {
+		t.Errorf("no test object for collector %s", name)
+		return
+	}
+	for _, f := range spec.families {
+		want, ok := spec.labelNames[f.Name]
+		if !ok {
+			continue
+		}
+		family := f.GenerateFunc(obj)
+		if len(family.Metrics) == 0 {
+			t.Errorf("%s: %s generated no metric", name, f.Name)
+		}
+		for _, m := range family.Metrics {
+			if !reflect.DeepEqual(m.LabelKeys, want) {
+				t.Errorf("%s: expected %s to have the labels %v, got %v", name, f.Name, want, m.LabelKeys)
+			}
+		}
+	}
+}
+
+func TestPrometheusCollectorsAlreadyRegistered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := prometheus.NewPedanticRegistry()
+	for i := 0; i < 2; i++ {
+		clients := newFakeClientFactory()
+		clients.route = routefake.NewSimpleClientset(newTestRoute("ns1", "r1"))
+		b := newTestBuilder(t, ctx, clients).WithEnabledCollectors([]string{"routes"})
+		waitForOutput(t, b.Build(), `route="r1"`)
+
+		err := registry.Register(b.PrometheusCollectors()[0])
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		switch {
+		case i == 0 && err != nil:
+			t.Fatal(err)
+		case i == 1 && !errors.As(err, &alreadyRegistered):
+			t.Errorf("expected the routes of the second builder to be registered already, got %v", err)
+		}
+	}
+
+	// The series of the families described with their labels are checked
+	// against them, the labels family is described by name only.
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP openshift_route_labels Kubernetes labels converted to Prometheus labels.
+# TYPE openshift_route_labels gauge
+openshift_route_labels{namespace="ns1",route="r1"} 1
+`), "openshift_route_labels"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,166 @@
+package collectors
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/cache"
+)
+
+// collectorReflectors runs the reflectors of a single collector, one per
+// namespace. Namespaces can be added and removed at any time, but reflectors
+// only run once the collector is started.
+type collectorReflectors struct {
+	ctx          context.Context
+	expectedType interface{}
+	listWatch    func(ns string) cache.ListWatch
+	store        *MetricsStore
+	// wrapStore optionally wraps the store of every reflector, e.g. to
+	// drop objects of other shards.
+	wrapStore func(cache.Store) cache.Store
+	health    *collectorHealth
+	// eventsByNamespace counts the watch events per namespace too.
+	eventsByNamespace bool
+	// listPageSize is the number of objects per page of the lists of the
+	// reflectors, 0 lists all objects at once.
+	listPageSize int64
+	// watchList streams the initial objects through a watch instead of
+	// listing them, if the apiserver supports it.
+	watchList bool
+
+	mu         sync.Mutex
+	started    bool
+	stopped    bool
+	namespaces map[string]*reflectorRun
+}
+
+// reflectorRun is a running reflector. It is nil for namespaces added before
+// the collector was started.
+type reflectorRun struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newCollectorReflectors(ctx context.Context, expectedType interface{}, listWatch func(ns string) cache.ListWatch, store *MetricsStore, health *collectorHealth) *collectorReflectors {
+	return &collectorReflectors{
+		ctx:          ctx,
+		expectedType: expectedType,
+		listWatch:    listWatch,
+		store:        store,
+		wrapStore:    func(s cache.Store) cache.Store { return s },
+		health:       health,
+		namespaces:   map[string]*reflectorRun{},
+	}
+}
+
+// start starts a reflector for every namespace added so far, and for every
+// namespace added from now on.
+func (r *collectorReflectors) start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return
+	}
+	r.started = true
+	r.health.start()
+	for ns := range r.namespaces {
+		r.namespaces[ns] = r.run(ns)
+	}
+}
+
+func (r *collectorReflectors) addNamespace(ns string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.namespaces[ns]; ok {
+		return
+	}
+	r.namespaces[ns] = nil
+	if r.started && !r.stopped {
+		r.namespaces[ns] = r.run(ns)
+	}
+}
+
+// removeNamespace stops the reflector of the given namespace and deletes the
+// metrics of all objects in that namespace.
+func (r *collectorReflectors) removeNamespace(ns string) {
+	r.mu.Lock()
+	run, ok := r.namespaces[ns]
+	delete(r.namespaces, ns)
+	r.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	if run != nil {
+		run.stop()
+	}
+	r.health.removeReflector(ns)
+	r.store.DeleteNamespace(ns)
+	NamespaceWatchEventsMetric.DeletePartialMatch(prometheus.Labels{"collector": r.health.name, "namespace": ns})
+}
+
+// namespaceList returns the sorted namespaces the collector has reflectors
+// for, metav1.NamespaceAll if it watches all namespaces at once.
+func (r *collectorReflectors) namespaceList() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	namespaces := make([]string, 0, len(r.namespaces))
+	for ns := range r.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// stop stops all reflectors and waits until none of them touches the store
+// anymore. No reflectors are started afterwards.
+func (r *collectorReflectors) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stopped = true
+	for ns, run := range r.namespaces {
+		if run != nil {
+			run.stop()
+			r.namespaces[ns] = nil
+		}
+	}
+}
+
+// run creates a Kubernetes client-go reflector for the given namespace and
+// registers it with the store of the collector. The reflector reports its
+// progress to the health of the collector.
+func (r *collectorReflectors) run(ns string) *reflectorRun {
+	ctx, cancel := context.WithCancel(r.ctx)
+	run := &reflectorRun{cancel: cancel, done: make(chan struct{})}
+
+	health := r.health.newReflector(ns)
+	lw := health.instrument(r.listWatch(ns))
+	events := newEventStore(r.wrapStore(r.store.forNamespace(ns)), r.health.name, r.eventsByNamespace)
+	store := &syncStore{Store: events, health: health}
+	reflector := cache.NewReflector(&lw, r.expectedType, store, 0)
+	reflector.WatchListPageSize = r.listPageSize
+	if r.watchList {
+		// The reflector falls back to lists if the apiserver does not
+		// support watch lists.
+		reflector.UseWatchList = true
+	}
+	go func() {
+		defer close(run.done)
+		reflector.Run(ctx.Done())
+	}()
+
+	return run
+}
+
+// stop stops the reflector and waits until it does not touch the store
+// anymore.
+func (run *reflectorRun) stop() {
+	run.cancel()
+	<-run.done
+}
@@ -0,0 +1,97 @@
+package collectors
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/kube-state-metrics/pkg/metric"
+)
+
+// CollectorFactory describes a collector added with RegisterCollector: which
+// objects it watches and which metrics it generates for them.
+type CollectorFactory interface {
+	// Resource is the watched resource. The collector is started once the
+	// apiserver serves it.
+	Resource() schema.GroupVersionResource
+	// Namespaced tells whether the objects live in namespaces, so that the
+	// namespace options apply to them.
+	Namespaced() bool
+	// ExpectedType is a pointer to the type of the watched objects, e.g.
+	// &routev1.Route{}.
+	ExpectedType() interface{}
+	// MetricFamilyGenerators generate the metrics of every object.
+	MetricFamilyGenerators() []metric.FamilyGenerator
+	// ListWatch lists and watches the objects of a namespace, or of all
+	// namespaces if it is empty, with the given clients. Cluster-scoped
+	// collectors always get an empty namespace.
+	ListWatch(clients ClientFactory, namespace string) cache.ListWatch
+}
+
+// KubeLabelsFamilies can be implemented by a CollectorFactory whose metrics
+// convert Kubernetes labels and annotations to Prometheus labels, so that the
+// labels and annotations allowlists apply to them. An empty name stands for
+// no such family.
+type KubeLabelsFamilies interface {
+	LabelsFamily() string
+	AnnotationsFamily() string
+}
+
+// collectorsMu protects availableCollectors, to which RegisterCollector adds
+// collectors.
+var collectorsMu sync.RWMutex
+
+// RegisterCollector makes a collector available under the given name, so that
+// it can be enabled with --collectors like the built-in ones. It is meant to
+// be called from the init functions of binaries embedding
+// openshift-state-metrics and panics if the name is empty or already taken.
+func RegisterCollector(name string, factory CollectorFactory) {
+	if name == "" || factory == nil {
+		panic("collectors: RegisterCollector needs a name and a factory")
+	}
+
+	spec := collectorSpec{
+		resource:      factory.Resource(),
+		families:      factory.MetricFamilyGenerators(),
+		expectedType:  factory.ExpectedType(),
+		scope:         clusterScoped,
+		listWatchFunc: factory.ListWatch,
+	}
+	if factory.Namespaced() {
+		spec.scope = namespaceScoped
+	}
+	if f, ok := factory.(KubeLabelsFamilies); ok {
+		spec.labelsFamily = f.LabelsFamily()
+		spec.annotationsFamily = f.AnnotationsFamily()
+	}
+
+	collectorsMu.Lock()
+	defer collectorsMu.Unlock()
+	if _, ok := availableCollectors[name]; ok {
+		panic(fmt.Sprintf("collectors: collector %s is registered twice", name))
+	}
+	availableCollectors[name] = spec
+}
+
+// lookupCollector returns the spec of the collector with the given name.
+func lookupCollector(name string) (collectorSpec, bool) {
+	collectorsMu.RLock()
+	defer collectorsMu.RUnlock()
+	spec, ok := availableCollectors[name]
+	return spec, ok
+}
+
+// AvailableCollectors returns the sorted names of the built-in collectors and
+// the ones added with RegisterCollector.
+func AvailableCollectors() []string {
+	collectorsMu.RLock()
+	defer collectorsMu.RUnlock()
+	names := make([]string, 0, len(availableCollectors))
+	for name := range availableCollectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
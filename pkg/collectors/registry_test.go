@@ -0,0 +1,74 @@
+package collectors
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/kube-state-metrics/pkg/metric"
+
+	userv1 "github.com/openshift/api/user/v1"
+	userfake "github.com/openshift/client-go/user/clientset/versioned/fake"
+)
+
+// groupSizeCollector is an in-house collector as embedders would register it.
+type groupSizeCollector struct{}
+
+func (groupSizeCollector) Resource() schema.GroupVersionResource {
+	return userv1.GroupVersion.WithResource("groups")
+}
+func (groupSizeCollector) Namespaced() bool          { return false }
+func (groupSizeCollector) ExpectedType() interface{} { return &userv1.Group{} }
+func (groupSizeCollector) ListWatch(clients ClientFactory, ns string) cache.ListWatch {
+	return createGroupListWatch(clients, ns)
+}
+func (groupSizeCollector) MetricFamilyGenerators() []metric.FamilyGenerator {
+	return []metric.FamilyGenerator{{
+		Name: "example_group_users",
+		Type: metric.MetricTypeGauge,
+		Help: "Number of users in a group.",
+		GenerateFunc: wrapGroupFunc(func(g *userv1.Group) metric.Family {
+			return metric.Family{Metrics: []*metric.Metric{{Value: float64(len(g.Users))}}}
+		}),
+	}}
+}
+
+func TestRegisterCollector(t *testing.T) {
+	RegisterCollector("groupsizes", groupSizeCollector{})
+	defer func() {
+		collectorsMu.Lock()
+		delete(availableCollectors, "groupsizes")
+		collectorsMu.Unlock()
+	}()
+
+	found := false
+	for _, name := range AvailableCollectors() {
+		found = found || name == "groupsizes"
+	}
+	if !found {
+		t.Errorf("expected groupsizes to be available, got %v", AvailableCollectors())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clients := newFakeClientFactory()
+	clients.user = userfake.NewSimpleClientset(&userv1.Group{
+		ObjectMeta: metav1.ObjectMeta{Name: "group1", UID: "group1"},
+		Users:      []string{"user1", "user2"},
+	})
+	b := newTestBuilder(t, ctx, clients).WithEnabledCollectors([]string{"groupsizes"})
+	waitForOutput(t, b.Build(), `example_group_users{group="group1"} 2`)
+
+	for name, factory := range map[string]CollectorFactory{"routes": groupSizeCollector{}, "": groupSizeCollector{}, "nil": nil} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected registering %q to panic", name)
+				}
+			}()
+			RegisterCollector(name, factory)
+		}()
+	}
+}
@@ -0,0 +1,195 @@
+package collectors
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+	"k8s.io/kube-state-metrics/pkg/metric"
+	"sigs.k8s.io/yaml"
+)
+
+// ReadObjects reads the objects of all YAML and JSON files below dir, like
+// the manifests of a must-gather dump. Files may hold several YAML documents
+// and lists. Documents which are not Kubernetes objects are skipped.
+func ReadObjects(dir string) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		read, err := readObjects(f)
+		if err != nil {
+			klog.Warningf("Skipping %s: %v", path, err)
+		}
+		objects = append(objects, read...)
+		return nil
+	})
+	return objects, err
+}
+
+// readObjects decodes the objects of all documents in r. It returns the
+// objects decoded before an invalid document along with the error.
+func readObjects(r io.Reader) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return objects, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		data, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return objects, err
+		}
+		obj, _, err := unstructured.UnstructuredJSONScheme.Decode(data, nil, nil)
+		if err != nil {
+			// Not a Kubernetes object, e.g. a config file of the dump.
+			klog.V(2).Infof("Skipping document: %v", err)
+			continue
+		}
+		switch obj := obj.(type) {
+		case *unstructured.Unstructured:
+			objects = append(objects, obj)
+		case *unstructured.UnstructuredList:
+			for i := range obj.Items {
+				objects = append(objects, &obj.Items[i])
+			}
+		}
+	}
+}
+
+// Render writes the metrics the enabled built-in collectors and the custom
+// resource state collectors generate for the given objects to w, as they
+// would be served on /metrics. Objects no collector is enabled for are
+// skipped with a warning per kind, and an error is returned if none of the
+// objects is rendered. The namespace selection and sharding do not apply.
+func (b *Builder) Render(w io.Writer, objects []*unstructured.Unstructured) error {
+	byKind := map[schema.GroupKind][]*unstructured.Unstructured{}
+	for _, o := range objects {
+		gk := o.GroupVersionKind().GroupKind()
+		byKind[gk] = append(byKind[gk], o)
+	}
+
+	// rendered maps the kinds rendered by a collector to its name.
+	rendered := map[schema.GroupKind]string{}
+	for _, name := range b.enabledCollectors {
+		spec, ok := lookupCollector(name)
+		if !ok {
+			return fmt.Errorf("collector %s is not correct", name)
+		}
+		gk := schema.GroupKind{Group: spec.resource.Group, Kind: reflect.TypeOf(spec.expectedType).Elem().Name()}
+		rendered[gk] = name
+		if err := b.render(w, name, spec, byKind[gk]); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range b.customResources {
+		gk := schema.GroupKind{Group: r.GroupVersionKind.Group, Kind: r.GroupVersionKind.Kind}
+		rendered[gk] = r.collectorName()
+		if err := b.render(w, r.collectorName(), r.collectorSpec(), byKind[gk]); err != nil {
+			return err
+		}
+	}
+
+	return checkRendered(byKind, rendered)
+}
+
+// checkRendered warns about the kinds of objects no collector rendered. It
+// fails if no object was rendered at all.
+func checkRendered(byKind map[schema.GroupKind][]*unstructured.Unstructured, rendered map[schema.GroupKind]string) error {
+	var skipped []schema.GroupKind
+	count := 0
+	for gk, objects := range byKind {
+		if _, ok := rendered[gk]; ok {
+			count += len(objects)
+			continue
+		}
+		skipped = append(skipped, gk)
+	}
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].String() < skipped[j].String() })
+
+	for _, gk := range skipped {
+		group := gk.Group
+		if group == "" {
+			group = "core"
+		}
+		reason := "no enabled collector renders it"
+		for other, name := range rendered {
+			if other.Kind == gk.Kind {
+				// E.g. a Route of the legacy apiVersion v1.
+				reason = fmt.Sprintf("collector %s only renders the %s group", name, other.Group)
+			}
+		}
+		klog.Warningf("Skipping kind %s in the %s group (objects: %d): %s", gk.Kind, group, len(byKind[gk]), reason)
+	}
+
+	if count == 0 && len(byKind) > 0 {
+		return errors.New("none of the objects is of a kind an enabled collector renders")
+	}
+	return nil
+}
+
+// render writes the metrics of the given collector for the objects to w.
+// The objects are converted to the type the collector expects.
+func (b *Builder) render(w io.Writer, name string, spec collectorSpec, objects []*unstructured.Unstructured) error {
+	families := b.metricFamilies(name, spec)
+	store := NewMetricsStore(metric.ExtractMetricFamilyHeaders(families), metric.ComposeMetricGenFuncs(families))
+	_, isUnstructured := spec.expectedType.(*unstructured.Unstructured)
+	objectType := reflect.TypeOf(spec.expectedType).Elem()
+	for _, u := range objects {
+		var obj interface{} = u.DeepCopy()
+		if !isUnstructured {
+			obj = reflect.New(objectType).Interface()
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj); err != nil {
+				return fmt.Errorf("cannot convert %s %s/%s: %w", objectType.Name(), u.GetNamespace(), u.GetName(), err)
+			}
+		}
+		if o, err := meta.Accessor(obj); err == nil && o.GetUID() == "" {
+			// The store is keyed by UID, which hand-written manifests lack.
+			o.SetUID(types.UID(u.GetNamespace() + "/" + u.GetName()))
+		}
+		if err := store.Add(obj); err != nil {
+			return err
+		}
+	}
+	store.WriteAll(w)
+	return nil
+}
@@ -0,0 +1,186 @@
+package collectors
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"namespaces/ns1/route.openshift.io/routes.yaml": `
+apiVersion: route.openshift.io/v1
+kind: RouteList
+items:
+- metadata:
+    name: route1
+    namespace: ns1
+  spec:
+    host: example.com
+    to: {kind: Service, name: svc1, weight: 100}
+`,
+		"cluster-scoped-resources/groups.yaml": `
+apiVersion: user.openshift.io/v1
+kind: Group
+metadata:
+  name: group1
+users: [user1]
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod1
+  namespace: ns1
+---
+not: an object
+`,
+		"cluster-scoped-resources/groups.json": `{"apiVersion": "user.openshift.io/v1", "kind": "Group", "metadata": {"name": "group2"}, "users": ["user2"]}`,
+		"broken.yaml":                          "broken: [",
+		"notes.txt":                            "kind: Group",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objects, err := ReadObjects(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 4 {
+		t.Fatalf("expected a route, two groups and a pod, got %d objects", len(objects))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := newTestBuilder(t, ctx, nil).WithEnabledCollectors([]string{"routes", "groups"})
+	buf := &bytes.Buffer{}
+	if err := b.Render(buf, objects); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`# TYPE openshift_route_info gauge`,
+		`openshift_route_info{namespace="ns1",route="route1",host="example.com"`,
+		`openshift_group_user_account{group="group1",user="user1"} 1`,
+		`openshift_group_user_account{group="group2",user="user2"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %s, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "openshift_build") {
+		t.Errorf("expected only the enabled collectors, got:\n%s", out)
+	}
+}
+
+func TestRenderCustomResourceState(t *testing.T) {
+	var config CustomResourceStateConfig
+	if err := yaml.UnmarshalStrict([]byte(widgetConfig), &config); err != nil {
+		t.Fatal(err)
+	}
+	objects, err := readObjects(strings.NewReader(`
+apiVersion: example.com/v1
+kind: WidgetList
+items:
+- metadata:
+    name: widget1
+    namespace: ns1
+  spec:
+    replicas: 3
+- metadata:
+    name: widget2
+    namespace: ns1
+  spec:
+    replicas: 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := newTestBuilder(t, ctx, nil).WithEnabledCollectors([]string{"routes"}).WithCustomResourceState(&config)
+	buf := &bytes.Buffer{}
+	if err := b.Render(buf, objects); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`# TYPE example_widget_replicas gauge`,
+		`example_widget_replicas{namespace="ns1",widget="widget1",team=""} 3`,
+		`example_widget_replicas{namespace="ns1",widget="widget2",team=""} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %s, got:\n%s", want, out)
+		}
+	}
+	if objects[0].GetUID() != "" {
+		t.Errorf("expected the objects to be left unchanged, got UID %s", objects[0].GetUID())
+	}
+}
+
+func TestRenderSkippedKinds(t *testing.T) {
+	objects, err := readObjects(strings.NewReader(`
+apiVersion: v1
+kind: Route
+metadata:
+  name: route1
+  namespace: ns1
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod1
+  namespace: ns1
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod2
+  namespace: ns1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := &bytes.Buffer{}
+	klog.LogToStderr(false)
+	klog.SetOutput(logs)
+	defer func() {
+		klog.SetOutput(os.Stderr)
+		klog.LogToStderr(true)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := newTestBuilder(t, ctx, nil).WithEnabledCollectors([]string{"routes"})
+	buf := &bytes.Buffer{}
+	if err := b.Render(buf, objects); err == nil {
+		t.Errorf("expected an error as no object is rendered, got:\n%s", buf.String())
+	}
+	klog.Flush()
+
+	for _, want := range []string{
+		"Skipping kind Pod in the core group (objects: 2): no enabled collector renders it",
+		"Skipping kind Route in the core group (objects: 1): collector routes only renders the route.openshift.io group",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected a warning %q, got:\n%s", want, logs.String())
+		}
+	}
+}
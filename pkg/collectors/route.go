@@ -8,20 +8,17 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/kube-state-metrics/pkg/metric"
-	"k8s.io/kube-state-metrics/pkg/version"
-
-	"k8s.io/klog/v2"
 
 	v1 "github.com/openshift/api/route/v1"
-	routeclient "github.com/openshift/client-go/route/clientset/versioned"
 )
 
 var (
 	descRouteLabelsName          = "openshift_route_labels"
 	descRouteLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descRouteLabelsDefaultLabels = []string{"namespace", "route"}
+	descRouteAnnotationsName     = "openshift_route_annotations"
+	descRouteAnnotationsHelp     = "Kubernetes annotations converted to Prometheus labels."
 
 	routeMetricFamilies = []metric.FamilyGenerator{
 		metric.FamilyGenerator{
@@ -111,6 +108,21 @@ var (
 				}}
 			}),
 		},
+		metric.FamilyGenerator{
+			Name: descRouteAnnotationsName,
+			Type: metric.MetricTypeGauge,
+			Help: descRouteAnnotationsHelp,
+			GenerateFunc: wrapRouteFunc(func(d *v1.Route) metric.Family {
+				annotationKeys, annotationValues := kubeAnnotationsToPrometheusLabels(d.Annotations)
+				return metric.Family{Metrics: []*metric.Metric{
+					{
+						LabelKeys:   annotationKeys,
+						LabelValues: annotationValues,
+						Value:       1,
+					},
+				}}
+			}),
+		},
 	}
 )
 
@@ -129,32 +141,13 @@ func wrapRouteFunc(f func(*v1.Route) metric.Family) func(interface{}) metric.Fam
 	}
 }
 
-func createRouteListWatch(apiserver string, kubeconfig string, ns string) cache.ListWatch {
-	routesclient, err := createRouteClient(apiserver, kubeconfig)
-	if err != nil {
-		klog.Fatalf("cannot create Route client: %v", err)
-	}
+func createRouteListWatch(clients ClientFactory, ns string) cache.ListWatch {
 	return cache.ListWatch{
 		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			return routesclient.RouteV1().Routes(ns).List(context.TODO(), opts)
+			return clients.RouteClient().RouteV1().Routes(ns).List(context.TODO(), opts)
 		},
 		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			return routesclient.RouteV1().Routes(ns).Watch(context.TODO(), opts)
+			return clients.RouteClient().RouteV1().Routes(ns).Watch(context.TODO(), opts)
 		},
 	}
 }
-
-func createRouteClient(apiserver string, kubeconfig string) (*routeclient.Clientset, error) {
-	config, err := clientcmd.BuildConfigFromFlags(apiserver, kubeconfig)
-	if err != nil {
-		return nil, err
-	}
-
-	config.UserAgent = version.GetVersion().String()
-	config.AcceptContentTypes = "application/vnd.kubernetes.protobuf,application/json"
-	config.ContentType = "application/vnd.kubernetes.protobuf"
-
-	client, err := routeclient.NewForConfig(config)
-	return client, err
-
-}
@@ -22,6 +22,8 @@ func TestRouteConfigCollector(t *testing.T) {
 		# TYPE openshift_route_created gauge
 		# HELP openshift_route_labels Kubernetes labels converted to Prometheus labels.
 		# TYPE openshift_route_labels gauge
+		# HELP openshift_route_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE openshift_route_annotations gauge
 		# HELP openshift_route_info Information about route.
 		# TYPE openshift_route_info gauge
 		# HELP openshift_route_status Information about route status.
@@ -37,6 +39,9 @@ func TestRouteConfigCollector(t *testing.T) {
 					Labels: map[string]string{
 						"app": "good1",
 					},
+					Annotations: map[string]string{
+						"example.com/owner": "team-a",
+					},
 				},
 				Status: v1.RouteStatus{
 					Ingress: []v1.RouteIngress{
@@ -77,11 +82,12 @@ func TestRouteConfigCollector(t *testing.T) {
 			Want: `
         openshift_route_created{route="route1",namespace="ns1"} 1.5e+09
         openshift_route_labels{route="route1",label_app="good1",namespace="ns1"} 1
+        openshift_route_annotations{route="route1",annotation_example_com_owner="team-a",namespace="ns1"} 1
 		openshift_route_info{route="route1",namespace="ns1",host="example.com",path="",tls_termination="edge",to_kind="Service",to_name="svc1",to_weight="100"} 1
 		openshift_route_status{route="route1",namespace="ns1",host="example.com",status="True",type="Admitted",router_name="router1"} 1
 		openshift_route_status{route="route1",namespace="ns1",host="example.com",status="True",type="Admitted",router_name="router2"} 1
 				`,
-			MetricNames: []string{"openshift_route_created", "openshift_route_labels", "openshift_route_info", "openshift_route_status"},
+			MetricNames: []string{"openshift_route_created", "openshift_route_labels", "openshift_route_annotations", "openshift_route_info", "openshift_route_status"},
 		},
 	}
 
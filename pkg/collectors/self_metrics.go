@@ -0,0 +1,130 @@
+package collectors
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	ListWatchRequestsMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openshift_state_metrics_list_watch_requests_total",
+			Help: "Number of list and watch requests of the reflectors of each collector by verb and result.",
+		},
+		[]string{"collector", "verb", "result"},
+	)
+
+	ListDurationMetric = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "openshift_state_metrics_list_duration_seconds",
+			Help:    "Duration of the list requests of the reflectors of each collector.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"collector"},
+	)
+
+	InitialListDurationMetric = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "openshift_state_metrics_initial_list_duration_seconds",
+			Help:    "Time from the start of each reflector of a collector until its initial list or watch list filled the store.",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+		},
+		[]string{"collector"},
+	)
+
+	WatchRestartsMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openshift_state_metrics_watch_restarts_total",
+			Help: "Number of watches the reflectors of each collector started again after the first one.",
+		},
+		[]string{"collector"},
+	)
+
+	WatchEventsMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openshift_state_metrics_watch_events_total",
+			Help: "Number of objects added, modified and deleted by the watches of each collector.",
+		},
+		[]string{"collector", "type"},
+	)
+
+	NamespaceWatchEventsMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openshift_state_metrics_namespace_watch_events_total",
+			Help: "Number of objects added, modified and deleted by the watches of each collector per namespace.",
+		},
+		[]string{"collector", "namespace", "type"},
+	)
+
+	StoreObjectsMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openshift_state_metrics_store_objects",
+			Help: "Number of objects in the store of each collector.",
+		},
+		[]string{"collector"},
+	)
+)
+
+// deleteCollectorMetrics removes the self metrics of a collector which is not
+// built anymore.
+func deleteCollectorMetrics(name string) {
+	labels := prometheus.Labels{"collector": name}
+	CollectorEnabledMetric.DeletePartialMatch(labels)
+	ListWatchRequestsMetric.DeletePartialMatch(labels)
+	ListDurationMetric.DeletePartialMatch(labels)
+	InitialListDurationMetric.DeletePartialMatch(labels)
+	WatchRestartsMetric.DeletePartialMatch(labels)
+	StoreObjectsMetric.DeletePartialMatch(labels)
+	WatchEventsMetric.DeletePartialMatch(labels)
+	NamespaceWatchEventsMetric.DeletePartialMatch(labels)
+	ScrapeErrorTotalMetric.DeletePartialMatch(prometheus.Labels{"resource": name})
+	ResourcesPerScrapeMetric.DeletePartialMatch(prometheus.Labels{"resource": name})
+}
+
+// eventStore counts the objects a reflector adds, updates and deletes as
+// watch events. Relists replace the store and do not count.
+type eventStore struct {
+	cache.Store
+	collector string
+	// byNamespace also counts the events per namespace of the objects.
+	byNamespace bool
+
+	added, modified, deleted prometheus.Counter
+}
+
+func newEventStore(store cache.Store, collector string, byNamespace bool) *eventStore {
+	return &eventStore{
+		Store:       store,
+		collector:   collector,
+		byNamespace: byNamespace,
+		added:       WatchEventsMetric.WithLabelValues(collector, "added"),
+		modified:    WatchEventsMetric.WithLabelValues(collector, "modified"),
+		deleted:     WatchEventsMetric.WithLabelValues(collector, "deleted"),
+	}
+}
+
+func (s *eventStore) Add(obj interface{}) error {
+	s.count(obj, s.added, "added")
+	return s.Store.Add(obj)
+}
+
+func (s *eventStore) Update(obj interface{}) error {
+	s.count(obj, s.modified, "modified")
+	return s.Store.Update(obj)
+}
+
+func (s *eventStore) Delete(obj interface{}) error {
+	s.count(obj, s.deleted, "deleted")
+	return s.Store.Delete(obj)
+}
+
+func (s *eventStore) count(obj interface{}, c prometheus.Counter, eventType string) {
+	c.Inc()
+	if !s.byNamespace {
+		return
+	}
+	if o, err := meta.Accessor(obj); err == nil && o.GetNamespace() != "" {
+		NamespaceWatchEventsMetric.WithLabelValues(s.collector, o.GetNamespace(), eventType).Inc()
+	}
+}
@@ -0,0 +1,155 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// shardedStore only passes objects whose UID hashes to the configured shard on
+// to the underlying store. All other objects are dropped, so that every
+// replica only holds the metrics of its own shard. A shard outside of
+// [0, totalShards), like the one of a pod terminating after its StatefulSet
+// was scaled down, keeps no objects at all.
+type shardedStore struct {
+	cache.Store
+	shard       uint64
+	totalShards uint64
+}
+
+func (s *shardedStore) keep(obj interface{}) bool {
+	if s.shard >= s.totalShards {
+		return false
+	}
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		// Let the underlying store deal with it.
+		return true
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(o.GetUID()))
+	return h.Sum64()%s.totalShards == s.shard
+}
+
+func (s *shardedStore) Add(obj interface{}) error {
+	if !s.keep(obj) {
+		return nil
+	}
+	return s.Store.Add(obj)
+}
+
+func (s *shardedStore) Update(obj interface{}) error {
+	if !s.keep(obj) {
+		return nil
+	}
+	return s.Store.Update(obj)
+}
+
+func (s *shardedStore) Replace(list []interface{}, resourceVersion string) error {
+	var kept []interface{}
+	if s.shard < s.totalShards {
+		kept = make([]interface{}, 0, len(list)/int(s.totalShards)+1)
+	}
+	for _, obj := range list {
+		if s.keep(obj) {
+			kept = append(kept, obj)
+		}
+	}
+	return s.Store.Replace(kept, resourceVersion)
+}
+
+// shardFromPodName returns the ordinal of a StatefulSet pod, e.g. 2 for
+// "openshift-state-metrics-2".
+func shardFromPodName(pod string) (int32, error) {
+	i := strings.LastIndex(pod, "-")
+	if i < 0 {
+		return 0, fmt.Errorf("pod name %q has no ordinal suffix", pod)
+	}
+	ordinal, err := strconv.ParseInt(pod[i+1:], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("pod name %q has no ordinal suffix: %v", pod, err)
+	}
+	return int32(ordinal), nil
+}
+
+// ResolveSharding determines the shard of the given pod and the total number of
+// shards from the StatefulSet owning the pod.
+func ResolveSharding(ctx context.Context, client kubernetes.Interface, namespace, pod string) (statefulSet string, shard int32, totalShards int32, err error) {
+	p, err := client.CoreV1().Pods(namespace).Get(ctx, pod, metav1.GetOptions{})
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("cannot get pod %s/%s: %v", namespace, pod, err)
+	}
+
+	owner := metav1.GetControllerOf(p)
+	if owner == nil || owner.Kind != "StatefulSet" {
+		return "", 0, 0, fmt.Errorf("pod %s/%s is not owned by a StatefulSet", namespace, pod)
+	}
+
+	sts, err := client.AppsV1().StatefulSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("cannot get statefulset %s/%s: %v", namespace, owner.Name, err)
+	}
+
+	shard, err = shardFromPodName(pod)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	totalShards = statefulSetReplicas(sts)
+	if shard < 0 || shard >= totalShards {
+		return "", 0, 0, fmt.Errorf("ordinal %d of pod %s/%s is not between 0 and %d", shard, namespace, pod, totalShards-1)
+	}
+
+	return sts.Name, shard, totalShards, nil
+}
+
+// WatchStatefulSetReplicas calls onChange whenever the number of replicas of
+// the given StatefulSet changes, until ctx is done.
+func WatchStatefulSetReplicas(ctx context.Context, client kubernetes.Interface, namespace, name string, replicas int32, onChange func(replicas int32)) {
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+			return client.AppsV1().StatefulSets(namespace).List(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+			return client.AppsV1().StatefulSets(namespace).Watch(ctx, opts)
+		},
+	}
+
+	update := func(obj interface{}) {
+		// AddFunc is called for the initial list too: the StatefulSet may
+		// have been scaled since the caller looked up its replicas.
+		if r := statefulSetReplicas(obj.(*appsv1.StatefulSet)); r != replicas {
+			klog.Infof("statefulset %s/%s scaled from %d to %d replicas", namespace, name, replicas, r)
+			replicas = r
+			onChange(r)
+		}
+	}
+
+	informer := cache.NewSharedIndexInformer(lw, &appsv1.StatefulSet{}, 0, cache.Indexers{})
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    update,
+		UpdateFunc: func(_, obj interface{}) { update(obj) },
+	})
+	informer.Run(ctx.Done())
+}
+
+func statefulSetReplicas(sts *appsv1.StatefulSet) int32 {
+	if sts.Spec.Replicas == nil {
+		return 1
+	}
+	return *sts.Spec.Replicas
+}
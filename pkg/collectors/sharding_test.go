@@ -0,0 +1,139 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	routev1 "github.com/openshift/api/route/v1"
+)
+
+// countingStore counts the objects added to it.
+type countingStore struct {
+	cache.Store
+	objects map[types.UID]struct{}
+}
+
+func (s *countingStore) Add(obj interface{}) error {
+	s.objects[obj.(*routev1.Route).UID] = struct{}{}
+	return nil
+}
+
+func (s *countingStore) Replace(list []interface{}, _ string) error {
+	s.objects = map[types.UID]struct{}{}
+	for _, obj := range list {
+		s.Add(obj)
+	}
+	return nil
+}
+
+func TestShardedStore(t *testing.T) {
+	const (
+		totalShards = 3
+		objects     = 300
+	)
+
+	list := []interface{}{}
+	for i := 0; i < objects; i++ {
+		list = append(list, &routev1.Route{ObjectMeta: metav1.ObjectMeta{UID: types.UID(fmt.Sprintf("uid-%d", i))}})
+	}
+
+	seen := map[types.UID]int{}
+	for shard := uint64(0); shard < totalShards; shard++ {
+		store := &countingStore{}
+		s := &shardedStore{Store: store, shard: shard, totalShards: totalShards}
+		if err := s.Replace(list, ""); err != nil {
+			t.Fatal(err)
+		}
+		if len(store.objects) == 0 || len(store.objects) == objects {
+			t.Errorf("expected shard %d to hold a part of the objects, got %d", shard, len(store.objects))
+		}
+		for uid := range store.objects {
+			seen[uid]++
+		}
+
+		// Adding an object must agree with replacing.
+		for _, obj := range list {
+			store.objects = map[types.UID]struct{}{}
+			s.Add(obj)
+			_, kept := store.objects[obj.(*routev1.Route).UID]
+			if kept != s.keep(obj) {
+				t.Errorf("expected Add and Replace to agree on %s", obj.(*routev1.Route).UID)
+			}
+		}
+	}
+
+	if len(seen) != objects {
+		t.Errorf("expected all %d objects to be kept by some shard, got %d", objects, len(seen))
+	}
+	for uid, n := range seen {
+		if n != 1 {
+			t.Errorf("expected %s to be kept by exactly one shard, got %d", uid, n)
+		}
+	}
+
+	// Shards left over after scaling down keep nothing.
+	for _, s := range []*shardedStore{{shard: 1, totalShards: 1}, {shard: 0, totalShards: 0}} {
+		store := &countingStore{}
+		s.Store = store
+		if err := s.Replace(list, ""); err != nil {
+			t.Fatal(err)
+		}
+		s.Add(list[0])
+		if len(store.objects) != 0 {
+			t.Errorf("expected shard %d of %d to keep no objects, got %d", s.shard, s.totalShards, len(store.objects))
+		}
+	}
+}
+
+func TestResolveSharding(t *testing.T) {
+	replicas := int32(3)
+	isController := true
+	client := kubefake.NewSimpleClientset(
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "osm", Namespace: "ns"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "osm-2",
+				Namespace: "ns",
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "StatefulSet", Name: "osm", Controller: &isController},
+				},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "osm-3",
+				Namespace: "ns",
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "StatefulSet", Name: "osm", Controller: &isController},
+				},
+			},
+		},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "standalone", Namespace: "ns"}},
+	)
+
+	sts, shard, totalShards, err := ResolveSharding(context.Background(), client, "ns", "osm-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sts != "osm" || shard != 2 || totalShards != 3 {
+		t.Errorf("expected shard 2 of 3 of statefulset osm, got shard %d of %d of statefulset %q", shard, totalShards, sts)
+	}
+
+	if _, _, _, err := ResolveSharding(context.Background(), client, "ns", "standalone"); err == nil {
+		t.Error("expected an error for a pod not owned by a StatefulSet")
+	}
+	if _, _, _, err := ResolveSharding(context.Background(), client, "ns", "osm-3"); err == nil {
+		t.Error("expected an error for an ordinal beyond the replicas of the StatefulSet")
+	}
+}
@@ -0,0 +1,156 @@
+package metricshandler
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	encodingGzip     = "gzip"
+	encodingZstd     = "zstd"
+	encodingIdentity = "identity"
+)
+
+// Compression configures how responses are compressed.
+type Compression struct {
+	// Encodings lists the content codings offered to clients, "gzip" and
+	// "zstd", in order of preference. Responses are not compressed if it is
+	// empty.
+	Encodings []string
+	// GzipLevel is the gzip compression level, from 1 (best speed) to 9
+	// (best compression).
+	GzipLevel int
+	// ZstdLevel is the zstd compression level, from 1 to 22. It is mapped
+	// to the closest level supported by the encoder.
+	ZstdLevel int
+}
+
+// Validate checks that only supported encodings and levels are configured.
+func (c Compression) Validate() error {
+	for _, e := range c.Encodings {
+		switch e {
+		case encodingGzip:
+			if c.GzipLevel < gzip.BestSpeed || c.GzipLevel > gzip.BestCompression {
+				return fmt.Errorf("gzip level must be between %d and %d, got %d", gzip.BestSpeed, gzip.BestCompression, c.GzipLevel)
+			}
+		case encodingZstd:
+			if c.ZstdLevel < 1 || c.ZstdLevel > 22 {
+				return fmt.Errorf("zstd level must be between 1 and 22, got %d", c.ZstdLevel)
+			}
+		default:
+			return fmt.Errorf("unsupported encoding %q, supported are %s and %s", e, encodingGzip, encodingZstd)
+		}
+	}
+	return nil
+}
+
+// compressors hands out pooled writers for the configured encodings.
+type compressors struct {
+	encodings []string
+	pools     map[string]*sync.Pool
+}
+
+func newCompressors(c Compression) *compressors {
+	cs := &compressors{encodings: c.Encodings, pools: map[string]*sync.Pool{}}
+	for _, e := range c.Encodings {
+		switch e {
+		case encodingGzip:
+			level := c.GzipLevel
+			cs.pools[e] = &sync.Pool{New: func() interface{} {
+				w, _ := gzip.NewWriterLevel(nil, level)
+				return w
+			}}
+		case encodingZstd:
+			level := zstd.EncoderLevelFromZstd(c.ZstdLevel)
+			cs.pools[e] = &sync.Pool{New: func() interface{} {
+				w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+				return w
+			}}
+		}
+	}
+	return cs
+}
+
+// resettableWriter is implemented by both *gzip.Writer and *zstd.Encoder.
+type resettableWriter interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+// writer returns a writer compressing into w with the given encoding. The
+// returned release function must be called once the writer is closed.
+func (cs *compressors) writer(encoding string, w io.Writer) (io.WriteCloser, func()) {
+	pool := cs.pools[encoding]
+	cw := pool.Get().(resettableWriter)
+	cw.Reset(w)
+	return cw, func() { pool.Put(cw) }
+}
+
+// negotiate picks the encoding of the response from the Accept-Encoding header
+// of the request, following RFC 9110, section 12.5.3. Of the encodings with
+// the highest quality, the one offered first wins. It returns "identity" if no
+// offered encoding is acceptable.
+func (cs *compressors) negotiate(acceptEncoding string) string {
+	if len(cs.encodings) == 0 || acceptEncoding == "" {
+		return encodingIdentity
+	}
+
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, q := parseCoding(part)
+		if coding != "" {
+			qualities[coding] = q
+		}
+	}
+
+	type candidate struct {
+		encoding string
+		q        float64
+	}
+	candidates := []candidate{}
+	for _, e := range cs.encodings {
+		q, ok := qualities[e]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > 0 {
+			candidates = append(candidates, candidate{e, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return encodingIdentity
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	// Clients preferring an uncompressed response get one.
+	if q, ok := qualities[encodingIdentity]; ok && q > candidates[0].q {
+		return encodingIdentity
+	}
+	return candidates[0].encoding
+}
+
+// parseCoding parses a single element of an Accept-Encoding header, e.g.
+// "gzip;q=0.8". Invalid quality values make the coding unacceptable.
+func parseCoding(s string) (string, float64) {
+	params := strings.Split(s, ";")
+	coding := strings.ToLower(strings.TrimSpace(params[0]))
+	q := 1.0
+	for _, p := range params[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok || strings.ToLower(strings.TrimSpace(key)) != "q" {
+			continue
+		}
+		var err error
+		if q, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil || q < 0 || q > 1 {
+			q = 0
+		}
+	}
+	return coding, q
+}
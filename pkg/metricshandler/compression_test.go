@@ -0,0 +1,52 @@
+package metricshandler
+
+import "testing"
+
+func TestNegotiateEncoding(t *testing.T) {
+	cs := newCompressors(Compression{Encodings: []string{"zstd", "gzip"}, GzipLevel: 6, ZstdLevel: 3})
+
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"", "identity"},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "gzip"},
+		{"GZIP;Q=0.5", "gzip"},
+		{"gzip, zstd", "zstd"},
+		{"gzip;q=1.0, zstd;q=0.5", "gzip"},
+		{"gzip;q=0, zstd;q=0", "identity"},
+		{"gzip;q=invalid", "identity"},
+		{"*", "zstd"},
+		{"*;q=0.5, zstd;q=0", "gzip"},
+		{"identity;q=1, gzip;q=0.5", "identity"},
+		{"br", "identity"},
+	}
+
+	for _, test := range tests {
+		if got := cs.negotiate(test.acceptEncoding); got != test.want {
+			t.Errorf("Accept-Encoding %q: expected %q, got %q", test.acceptEncoding, test.want, got)
+		}
+	}
+
+	if got := newCompressors(Compression{}).negotiate("gzip"); got != "identity" {
+		t.Errorf("expected no compression if no encodings are configured, got %q", got)
+	}
+}
+
+func TestCompressionValidate(t *testing.T) {
+	valid := Compression{Encodings: []string{"gzip", "zstd"}, GzipLevel: 9, ZstdLevel: 22}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, c := range []Compression{
+		{Encodings: []string{"br"}},
+		{Encodings: []string{"gzip"}, GzipLevel: 10},
+		{Encodings: []string{"zstd"}, ZstdLevel: 0},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
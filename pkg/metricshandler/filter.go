@@ -0,0 +1,57 @@
+package metricshandler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// familyFilterWriter only passes on the metric families it includes. The
+// family of a line is the one named by the last HELP or TYPE line.
+type familyFilterWriter struct {
+	lineWriter
+	w        io.Writer
+	families map[string]struct{}
+	// include tells whether the lines of the current family are written.
+	include bool
+}
+
+func newFamilyFilterWriter(w io.Writer, families map[string]struct{}) *familyFilterWriter {
+	f := &familyFilterWriter{w: w, families: families}
+	f.lineWriter = newLineWriter(f.filterLine)
+	return f
+}
+
+func (f *familyFilterWriter) filterLine(line []byte) error {
+	if fields := bytes.SplitN(line, []byte(" "), 4); len(fields) >= 3 && string(fields[0]) == "#" {
+		if keyword := string(fields[1]); keyword == "HELP" || keyword == "TYPE" {
+			_, f.include = f.families[string(bytes.TrimSuffix(fields[2], []byte("\n")))]
+		}
+	}
+	if !f.include {
+		return nil
+	}
+	_, err := f.w.Write(line)
+	return err
+}
+
+// Close writes the incomplete last line, if any.
+func (f *familyFilterWriter) Close() error {
+	return f.flush()
+}
+
+// queryList returns the values of the given query parameter, which can be
+// repeated and hold comma-separated lists. It returns nil if the parameter is
+// not set.
+func queryList(r *http.Request, name string) []string {
+	var values []string
+	for _, v := range r.URL.Query()[name] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,48 @@
+package metricshandler
+
+import "bytes"
+
+// lineWriter splits what is written to it into lines and calls writeLine
+// with every line, including its newline. Lines spanning several writes are
+// buffered until they are complete. Writing stops at the first error of
+// writeLine.
+type lineWriter struct {
+	writeLine func(line []byte) error
+	// line holds an incomplete line until the rest of it is written.
+	line []byte
+	err  error
+}
+
+func newLineWriter(writeLine func(line []byte) error) lineWriter {
+	return lineWriter{writeLine: writeLine}
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 && l.err == nil {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			l.line = append(l.line, p...)
+			break
+		}
+		if len(l.line) > 0 {
+			l.line = append(l.line, p[:i+1]...)
+			l.err = l.writeLine(l.line)
+			l.line = l.line[:0]
+		} else {
+			l.err = l.writeLine(p[:i+1])
+		}
+		p = p[i+1:]
+	}
+	return n, l.err
+}
+
+// flush calls writeLine with the incomplete last line, if any, terminated by
+// a newline.
+func (l *lineWriter) flush() error {
+	if len(l.line) > 0 && l.err == nil {
+		l.err = l.writeLine(append(l.line, '\n'))
+		l.line = nil
+	}
+	return l.err
+}
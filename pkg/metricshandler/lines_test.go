@@ -0,0 +1,52 @@
+package metricshandler
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	l := newLineWriter(func(line []byte) error {
+		lines = append(lines, string(line))
+		return nil
+	})
+
+	// Lines are passed on complete, however they are split across writes.
+	for _, p := range []string{"a 1\nb", " 2", "\n", "c 3\nd 4\n", "e 5"} {
+		if n, err := l.Write([]byte(p)); n != len(p) || err != nil {
+			t.Fatalf("expected %d bytes to be written, got %d, %v", len(p), n, err)
+		}
+	}
+	if err := l.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"a 1\n", "b 2\n", "c 3\n", "d 4\n", "e 5\n"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("expected lines %q, got %q", want, lines)
+	}
+}
+
+func TestLineWriterError(t *testing.T) {
+	failed := errors.New("failed")
+	calls := 0
+	l := newLineWriter(func([]byte) error {
+		calls++
+		return failed
+	})
+
+	if _, err := l.Write([]byte("a 1\nb 2\n")); err != failed {
+		t.Errorf("expected the error of the first line, got %v", err)
+	}
+	if _, err := l.Write([]byte("c 3\nd")); err != failed {
+		t.Errorf("expected later writes to fail, got %v", err)
+	}
+	if err := l.flush(); err != failed {
+		t.Errorf("expected flush to fail, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected writing to stop after the first error, got %d lines", calls)
+	}
+}
@@ -0,0 +1,170 @@
+package metricshandler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/kube-state-metrics/pkg/collector"
+)
+
+var (
+	CollectDurationMetric = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "openshift_state_metrics_collect_duration_seconds",
+			Help:    "Time each collector spent writing its metrics into a /metrics response.",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+		},
+		[]string{"collector"},
+	)
+
+	CollectBytesMetric = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "openshift_state_metrics_collect_bytes",
+			Help:    "Uncompressed bytes each collector wrote into a /metrics response.",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 10),
+		},
+		[]string{"collector"},
+	)
+)
+
+// MetricsHandler serves the metrics of a set of collectors, which can be
+// replaced at runtime. It responds in the OpenMetrics format if the client
+// asks for it and in the Prometheus text format otherwise. Responses are
+// compressed with the best encoding accepted by the client, if any.
+//
+// Clients can narrow the response down to some collectors with the collector
+// query parameter and to some metric families with the name[] query
+// parameter, e.g. /metrics?collector=builds&name[]=openshift_build_info.
+type MetricsHandler struct {
+	compressors *compressors
+
+	mu         sync.RWMutex
+	collectors []NamedCollector
+}
+
+// NamedCollector is a collector clients can select by its name.
+type NamedCollector struct {
+	Name      string
+	Collector *collector.Collector
+}
+
+// New returns a new MetricsHandler without any collectors. The compression
+// must be valid, see Compression.Validate.
+func New(compression Compression) *MetricsHandler {
+	return &MetricsHandler{compressors: newCompressors(compression)}
+}
+
+// SetCollectors replaces the collectors served by the handler.
+func (m *MetricsHandler) SetCollectors(collectors []NamedCollector) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make(map[string]bool, len(collectors))
+	for _, c := range collectors {
+		names[c.Name] = true
+	}
+	for _, c := range m.collectors {
+		if !names[c.Name] {
+			CollectDurationMetric.DeleteLabelValues(c.Name)
+			CollectBytesMetric.DeleteLabelValues(c.Name)
+		}
+	}
+	m.collectors = collectors
+}
+
+func (m *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	collectors := m.collectors
+	m.mu.RUnlock()
+
+	if names := queryList(r, "collector"); len(names) > 0 {
+		byName := make(map[string]NamedCollector, len(collectors))
+		for _, c := range collectors {
+			byName[c.Name] = c
+		}
+		selected := make([]NamedCollector, 0, len(names))
+		for _, name := range names {
+			c, ok := byName[name]
+			if !ok {
+				http.Error(w, fmt.Sprintf("Unknown collector %q", name), http.StatusBadRequest)
+				return
+			}
+			selected = append(selected, c)
+		}
+		collectors = selected
+	}
+
+	m.serve(w, r, func(w io.Writer) {
+		for _, c := range collectors {
+			start := time.Now()
+			cw := &countingWriter{w: w}
+			c.Collector.Collect(cw)
+			CollectDurationMetric.WithLabelValues(c.Name).Observe(time.Since(start).Seconds())
+			CollectBytesMetric.WithLabelValues(c.Name).Observe(float64(cw.n))
+		}
+	})
+}
+
+// serve responds with the metrics written by collect, in the format and with
+// the encoding negotiated with the client. Only the metric families given by
+// the name[] query parameter are written, if it is set.
+func (m *MetricsHandler) serve(w http.ResponseWriter, r *http.Request, collect func(io.Writer)) {
+	resHeader := w.Header()
+	var writer io.Writer = w
+
+	format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+	if strings.HasPrefix(string(format), expfmt.OpenMetricsType) {
+		resHeader.Set("Content-Type", string(format))
+	} else {
+		resHeader.Set("Content-Type", `text/plain; version=`+"0.0.4")
+	}
+
+	if len(m.compressors.encodings) > 0 {
+		resHeader.Add("Vary", "Accept-Encoding")
+	}
+	if encoding := m.compressors.negotiate(r.Header.Get("Accept-Encoding")); encoding != encodingIdentity {
+		compressor, release := m.compressors.writer(encoding, writer)
+		defer release()
+		// Closed after the OpenMetrics writer below, which terminates
+		// the exposition.
+		defer compressor.Close()
+		resHeader.Set("Content-Encoding", encoding)
+		writer = compressor
+	}
+
+	if strings.HasPrefix(string(format), expfmt.OpenMetricsType) {
+		om := newOpenMetricsWriter(writer)
+		defer om.Close()
+		writer = om
+	}
+
+	if names := queryList(r, "name[]"); len(names) > 0 {
+		families := make(map[string]struct{}, len(names))
+		for _, name := range names {
+			families[name] = struct{}{}
+		}
+		filter := newFamilyFilterWriter(writer, families)
+		defer filter.Close()
+		writer = filter
+	}
+
+	collect(writer)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
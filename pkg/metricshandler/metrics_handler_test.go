@@ -0,0 +1,283 @@
+package metricshandler
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/kube-state-metrics/pkg/collector"
+)
+
+// textStore writes a fixed exposition, one byte at a time to make sure lines
+// split across writes are handled.
+type textStore string
+
+func (s textStore) WriteAll(w io.Writer) {
+	for i := 0; i < len(s); i++ {
+		w.Write([]byte{s[i]})
+	}
+}
+
+const exposition = `# HELP openshift_route_created Unix creation timestamp
+# TYPE openshift_route_created gauge
+openshift_route_created{namespace="ns1",route="r1"} 1.5e+09
+# HELP openshift_build_duration_seconds Duration of the "build"
+# TYPE openshift_build_duration_seconds gauge
+openshift_build_duration_seconds{namespace="ns1",build="b1"} 42
+# HELP example_widget_restarts_total Restarts of the widget.
+# TYPE example_widget_restarts_total counter
+example_widget_restarts_total{widget="w1"} 3
+`
+
+func TestMetricsHandlerContentNegotiation(t *testing.T) {
+	handler := New(Compression{})
+	handler.SetCollectors([]NamedCollector{{Name: "test", Collector: collector.NewCollector(textStore(exposition))}})
+
+	tests := []struct {
+		accept      string
+		contentType string
+		want        string
+	}{
+		{
+			accept:      "",
+			contentType: "text/plain; version=0.0.4",
+			want:        exposition,
+		},
+		{
+			accept:      "text/plain;version=0.0.4;q=0.5,*/*;q=0.1",
+			contentType: "text/plain; version=0.0.4",
+			want:        exposition,
+		},
+		{
+			accept:      "application/openmetrics-text;version=1.0.0,application/openmetrics-text;version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1",
+			contentType: "application/openmetrics-text; version=1.0.0; charset=utf-8",
+			want: `# HELP openshift_route_created Unix creation timestamp
+# TYPE openshift_route_created gauge
+openshift_route_created{namespace="ns1",route="r1"} 1.5e+09
+# HELP openshift_build_duration_seconds Duration of the \"build\"
+# TYPE openshift_build_duration_seconds gauge
+# UNIT openshift_build_duration_seconds seconds
+openshift_build_duration_seconds{namespace="ns1",build="b1"} 42
+# HELP example_widget_restarts Restarts of the widget.
+# TYPE example_widget_restarts counter
+example_widget_restarts_total{widget="w1"} 3
+# EOF
+`,
+		},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Content-Type"); got != test.contentType {
+			t.Errorf("Accept %q: expected content type %q, got %q", test.accept, test.contentType, got)
+		}
+		if got := w.Body.String(); got != test.want {
+			t.Errorf("Accept %q: expected body\n%s\ngot\n%s", test.accept, test.want, got)
+		}
+	}
+}
+
+func TestMetricsHandlerCompression(t *testing.T) {
+	handler := New(Compression{Encodings: []string{"zstd", "gzip"}, GzipLevel: 6, ZstdLevel: 3})
+	handler.SetCollectors([]NamedCollector{{Name: "test", Collector: collector.NewCollector(textStore(exposition))}})
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"": func(r io.Reader) (io.Reader, error) { return r, nil },
+		"gzip": func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+		"zstd": func(r io.Reader) (io.Reader, error) {
+			d, err := zstd.NewReader(r)
+			return d, err
+		},
+	}
+
+	tests := []struct {
+		acceptEncoding string
+		encoding       string
+	}{
+		{"", ""},
+		{"br", ""},
+		{"gzip", "gzip"},
+		{"gzip;q=0.5, zstd", "zstd"},
+		{"gzip, zstd;q=0.5", "gzip"},
+	}
+
+	// Run twice to make sure pooled writers are reset properly.
+	for i := 0; i < 2; i++ {
+		for _, test := range tests {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			r.Header.Set("Accept-Encoding", test.acceptEncoding)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Content-Encoding"); got != test.encoding {
+				t.Errorf("Accept-Encoding %q: expected Content-Encoding %q, got %q", test.acceptEncoding, test.encoding, got)
+				continue
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Accept-Encoding %q: expected Vary: Accept-Encoding, got %q", test.acceptEncoding, got)
+			}
+
+			body, err := decoders[test.encoding](w.Body)
+			if err != nil {
+				t.Fatalf("Accept-Encoding %q: %v", test.acceptEncoding, err)
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("Accept-Encoding %q: %v", test.acceptEncoding, err)
+			}
+			if string(got) != exposition {
+				t.Errorf("Accept-Encoding %q: expected decoded body\n%s\ngot\n%s", test.acceptEncoding, exposition, got)
+			}
+		}
+	}
+}
+
+func TestMetricsHandlerCompressedOpenMetrics(t *testing.T) {
+	handler := New(Compression{Encodings: []string{"gzip"}, GzipLevel: 6})
+	handler.SetCollectors([]NamedCollector{{Name: "test", Collector: collector.NewCollector(textStore(exposition))}})
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set("Accept", "application/openmetrics-text;version=1.0.0")
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	body, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(got), "\n# EOF\n") {
+		t.Errorf("expected the compressed exposition to end with # EOF, got\n%s", got)
+	}
+}
+
+func TestMetricsHandlerSelection(t *testing.T) {
+	handler := New(Compression{})
+	handler.SetCollectors([]NamedCollector{
+		{Name: "routes", Collector: collector.NewCollector(textStore(`# HELP openshift_route_created Unix creation timestamp
+# TYPE openshift_route_created gauge
+openshift_route_created{namespace="ns1",route="r1"} 1.5e+09
+# HELP openshift_route_status Information about route status
+# TYPE openshift_route_status gauge
+openshift_route_status{namespace="ns1",route="r1"} 1
+`))},
+		{Name: "builds", Collector: collector.NewCollector(textStore(`# HELP openshift_build_created Unix creation timestamp
+# TYPE openshift_build_created gauge
+openshift_build_created{namespace="ns1",build="b1"} 1.5e+09
+`))},
+	})
+
+	tests := []struct {
+		query string
+		code  int
+		want  []string
+	}{
+		{
+			query: "",
+			code:  http.StatusOK,
+			want:  []string{"openshift_route_created", "openshift_route_status", "openshift_build_created"},
+		},
+		{
+			query: "collector=builds",
+			code:  http.StatusOK,
+			want:  []string{"openshift_build_created"},
+		},
+		{
+			query: "collector=builds,routes",
+			code:  http.StatusOK,
+			want:  []string{"openshift_build_created", "openshift_route_created", "openshift_route_status"},
+		},
+		{
+			query: "name[]=openshift_route_status&name[]=openshift_build_created",
+			code:  http.StatusOK,
+			want:  []string{"openshift_route_status", "openshift_build_created"},
+		},
+		{
+			query: "collector=routes&name[]=openshift_route_status&name[]=openshift_build_created",
+			code:  http.StatusOK,
+			want:  []string{"openshift_route_status"},
+		},
+		{
+			query: "name[]=openshift_unknown",
+			code:  http.StatusOK,
+			want:  nil,
+		},
+		{
+			query: "collector=unknown",
+			code:  http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/metrics?"+test.query, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%q: expected %d, got %d", test.query, test.code, w.Code)
+			continue
+		}
+		if test.code != http.StatusOK {
+			continue
+		}
+		var got []string
+		for _, line := range strings.Split(w.Body.String(), "\n") {
+			if strings.HasPrefix(line, "# TYPE ") {
+				got = append(got, strings.Fields(line)[2])
+			} else if line != "" && !strings.HasPrefix(line, "# ") {
+				// Every sample must follow the header of its family.
+				if name := line[:strings.IndexAny(line, "{ ")]; len(got) == 0 || got[len(got)-1] != name {
+					t.Errorf("%q: unexpected sample %s", test.query, line)
+				}
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%q: expected families %v, got %v", test.query, test.want, got)
+		}
+	}
+}
+
+func TestMetricsHandlerCollectMetrics(t *testing.T) {
+	handler := New(Compression{})
+	handler.SetCollectors([]NamedCollector{{Name: "selfmetrics", Collector: collector.NewCollector(textStore(exposition))}})
+
+	for i := 0; i < 2; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	}
+
+	m := &dto.Metric{}
+	if err := CollectBytesMetric.WithLabelValues("selfmetrics").(prometheus.Histogram).Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if h := m.GetHistogram(); h.GetSampleCount() != 2 || h.GetSampleSum() != float64(2*len(exposition)) {
+		t.Errorf("expected 2 scrapes of %d bytes, got %d scrapes of %v bytes", len(exposition), h.GetSampleCount(), h.GetSampleSum())
+	}
+	if testutil.CollectAndCount(CollectDurationMetric) == 0 {
+		t.Errorf("expected the collect duration to be observed")
+	}
+
+	// The metrics of collectors which are gone are dropped.
+	handler.SetCollectors(nil)
+	if CollectBytesMetric.DeleteLabelValues("selfmetrics") || CollectDurationMetric.DeleteLabelValues("selfmetrics") {
+		t.Errorf("expected the metrics of the removed collector to be dropped")
+	}
+}
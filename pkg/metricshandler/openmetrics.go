@@ -0,0 +1,116 @@
+package metricshandler
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// units are the metric name suffixes exposed as OpenMetrics units.
+var units = []string{"seconds", "bytes"}
+
+// openMetricsWriter converts the Prometheus text format written by the
+// metric stores to OpenMetrics on the fly:
+//
+//   - HELP texts have their double quotes escaped.
+//   - Counter families are named without their "_total" suffix, counters
+//     without it are exposed as unknown.
+//   - Families whose name ends with a unit get a UNIT line.
+//   - Gauges ending with "_created", like openshift_route_created, stay
+//     gauges holding a timestamp. Only counters have OpenMetrics "_created"
+//     samples, and none are written since the creation time of a counter is
+//     not known.
+//
+// Close must be called to terminate the exposition with "# EOF".
+type openMetricsWriter struct {
+	lineWriter
+	w io.Writer
+	// helpName and help hold a HELP line until the TYPE line of the
+	// family is seen.
+	helpName string
+	help     string
+	err      error
+}
+
+func newOpenMetricsWriter(w io.Writer) *openMetricsWriter {
+	o := &openMetricsWriter{w: w}
+	o.lineWriter = newLineWriter(o.convertLine)
+	return o
+}
+
+func (o *openMetricsWriter) convertLine(line []byte) error {
+	if !bytes.HasPrefix(line, []byte("# ")) {
+		o.flushHelp()
+		o.write(line)
+		return o.err
+	}
+
+	fields := strings.SplitN(strings.TrimSuffix(string(line[2:]), "\n"), " ", 3)
+	if len(fields) < 3 {
+		o.flushHelp()
+		o.write(line)
+		return o.err
+	}
+	keyword, name, text := fields[0], fields[1], fields[2]
+
+	switch keyword {
+	case "HELP":
+		o.flushHelp()
+		o.helpName, o.help = name, strings.ReplaceAll(text, `"`, `\"`)
+	case "TYPE":
+		family, typ := name, text
+		switch typ {
+		case "gauge":
+		case "counter":
+			if strings.HasSuffix(name, "_total") {
+				family = strings.TrimSuffix(name, "_total")
+			} else {
+				typ = "unknown"
+			}
+		default:
+			typ = "unknown"
+		}
+
+		if o.helpName == name {
+			o.write([]byte("# HELP " + family + " " + o.help + "\n"))
+			o.helpName = ""
+		}
+		o.flushHelp()
+		o.write([]byte("# TYPE " + family + " " + typ + "\n"))
+		for _, unit := range units {
+			if strings.HasSuffix(family, "_"+unit) {
+				o.write([]byte("# UNIT " + family + " " + unit + "\n"))
+				break
+			}
+		}
+	default:
+		o.flushHelp()
+		o.write(line)
+	}
+	return o.err
+}
+
+// flushHelp writes a HELP line which was not followed by a TYPE line.
+func (o *openMetricsWriter) flushHelp() {
+	if o.helpName == "" {
+		return
+	}
+	name := o.helpName
+	o.helpName = ""
+	o.write([]byte("# HELP " + name + " " + o.help + "\n"))
+}
+
+func (o *openMetricsWriter) write(p []byte) {
+	if o.err != nil {
+		return
+	}
+	_, o.err = o.w.Write(p)
+}
+
+// Close writes any incomplete line and terminates the exposition.
+func (o *openMetricsWriter) Close() error {
+	o.flush()
+	o.flushHelp()
+	o.write([]byte("# EOF\n"))
+	return o.err
+}
@@ -0,0 +1,128 @@
+package metricshandler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/openshift-state-metrics/pkg/auth"
+)
+
+// maxTenantNamespaces is the maximum number of namespaces of a request. Every
+// namespace costs a SubjectAccessReview per collector.
+const maxTenantNamespaces = 50
+
+// TenantStore writes the metrics of the objects in the allowed namespaces.
+type TenantStore interface {
+	WriteNamespaces(w io.Writer, allowed func(namespace string) bool)
+}
+
+// TenantCollector is a collector of namespaced objects whose metrics are
+// served per namespace.
+type TenantCollector struct {
+	// Resource is the resource the collector lists. Callers only get the
+	// metrics of the namespaces they may list the resource in.
+	Resource schema.GroupVersionResource
+	Store    TenantStore
+}
+
+// TenantHandler serves the metrics of the namespaces given by the namespace
+// query parameter, which can be repeated and hold comma-separated lists.
+// Every collector only writes the namespaces in which the caller may list its
+// resource, which is checked with SubjectAccessReviews. Metrics of
+// cluster-scoped objects are never served.
+type TenantHandler struct {
+	metrics   *MetricsHandler
+	delegator *auth.Delegator
+
+	mu         sync.RWMutex
+	collectors []TenantCollector
+}
+
+// NewTenantHandler returns a new TenantHandler without any collectors.
+func NewTenantHandler(compression Compression, delegator *auth.Delegator) *TenantHandler {
+	return &TenantHandler{metrics: New(compression), delegator: delegator}
+}
+
+// SetCollectors replaces the collectors served by the handler.
+func (t *TenantHandler) SetCollectors(collectors []TenantCollector) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.collectors = collectors
+}
+
+func (t *TenantHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := t.delegator.Authenticate(r)
+	if err != nil {
+		klog.Errorf("Failed to authenticate request to %s: %v", r.URL.Path, err)
+	}
+	if u == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	namespaces := queryNamespaces(r)
+	if len(namespaces) == 0 {
+		http.Error(w, "At least one namespace must be given with the namespace parameter", http.StatusBadRequest)
+		return
+	}
+	if len(namespaces) > maxTenantNamespaces {
+		http.Error(w, fmt.Sprintf("At most %d namespaces can be given with the namespace parameter", maxTenantNamespaces), http.StatusBadRequest)
+		return
+	}
+
+	t.mu.RLock()
+	collectors := t.collectors
+	t.mu.RUnlock()
+
+	allowed := make([]map[string]bool, len(collectors))
+	anyAllowed := false
+	for i, c := range collectors {
+		allowed[i] = map[string]bool{}
+		for _, ns := range namespaces {
+			attrs := &authorizationv1.ResourceAttributes{Namespace: ns, Group: c.Resource.Group, Resource: c.Resource.Resource}
+			ok, _, err := t.delegator.Authorize(r.Context(), u, "list", attrs, "")
+			if err != nil {
+				klog.Errorf("Failed to authorize user %q: %v", u.Name, err)
+				http.Error(w, "Authorization error", http.StatusInternalServerError)
+				return
+			}
+			if ok {
+				allowed[i][ns] = true
+				anyAllowed = true
+			}
+		}
+	}
+	if !anyAllowed {
+		http.Error(w, fmt.Sprintf("Forbidden (user=%s, namespaces=%s)", u.Name, strings.Join(namespaces, ",")), http.StatusForbidden)
+		return
+	}
+
+	t.metrics.serve(w, r, func(w io.Writer) {
+		for i, c := range collectors {
+			c.Store.WriteNamespaces(w, func(ns string) bool { return allowed[i][ns] })
+		}
+	})
+}
+
+// queryNamespaces returns the sorted, unique namespaces of the namespace query
+// parameter.
+func queryNamespaces(r *http.Request) []string {
+	set := map[string]struct{}{}
+	for _, ns := range queryList(r, "namespace") {
+		set[ns] = struct{}{}
+	}
+	namespaces := make([]string, 0, len(set))
+	for ns := range set {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
@@ -0,0 +1,137 @@
+package metricshandler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/openshift/openshift-state-metrics/pkg/auth"
+)
+
+// namespaceStore holds one metric per namespace.
+type namespaceStore struct {
+	name       string
+	namespaces []string
+}
+
+func (s namespaceStore) WriteNamespaces(w io.Writer, allowed func(string) bool) {
+	fmt.Fprintf(w, "# HELP %s Test.\n# TYPE %s gauge\n", s.name, s.name)
+	for _, ns := range s.namespaces {
+		if allowed(ns) {
+			fmt.Fprintf(w, "%s{namespace=%q} 1\n", s.name, ns)
+		}
+	}
+}
+
+// newTenantTestDelegator authenticates the token "alice" as the user alice,
+// who may list builds in team-a and routes in team-a and team-b.
+func newTenantTestDelegator() *auth.Delegator {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == "alice" {
+			review.Status.Authenticated = true
+			review.Status.User.Username = "alice"
+		}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		a := review.Spec.ResourceAttributes
+		if review.Spec.User == "alice" && a != nil && a.Verb == "list" && a.Group == "build.openshift.io" {
+			review.Status.Allowed = a.Resource == "builds" && a.Namespace == "team-a"
+		}
+		if review.Spec.User == "alice" && a != nil && a.Verb == "list" && a.Group == "route.openshift.io" {
+			review.Status.Allowed = a.Resource == "routes" && (a.Namespace == "team-a" || a.Namespace == "team-b")
+		}
+		return true, review, nil
+	})
+	return auth.NewDelegator(client, auth.Config{CacheTTL: time.Minute})
+}
+
+func TestTenantHandler(t *testing.T) {
+	namespaces := make([]string, maxTenantNamespaces+1)
+	for i := range namespaces {
+		namespaces[i] = fmt.Sprintf("team-%d", i)
+	}
+	tooManyNamespaces := "namespace=" + strings.Join(namespaces, ",")
+
+	handler := NewTenantHandler(Compression{}, newTenantTestDelegator())
+	handler.SetCollectors([]TenantCollector{
+		{
+			Resource: schema.GroupVersionResource{Group: "build.openshift.io", Version: "v1", Resource: "builds"},
+			Store:    namespaceStore{"openshift_build_info", []string{"team-a", "team-b", "team-c"}},
+		},
+		{
+			Resource: schema.GroupVersionResource{Group: "route.openshift.io", Version: "v1", Resource: "routes"},
+			Store:    namespaceStore{"openshift_route_info", []string{"team-a", "team-b", "team-c"}},
+		},
+	})
+
+	tests := []struct {
+		query string
+		token string
+		code  int
+		want  string
+	}{
+		{query: "namespace=team-a", code: http.StatusUnauthorized},
+		{query: "namespace=team-a", token: "mallory", code: http.StatusUnauthorized},
+		{query: "", token: "alice", code: http.StatusBadRequest},
+		{query: "namespace=team-c", token: "alice", code: http.StatusForbidden},
+		{query: "namespace=" + strings.Repeat("team-a,", maxTenantNamespaces) + "team-b", token: "alice", code: http.StatusOK},
+		{query: tooManyNamespaces, token: "alice", code: http.StatusBadRequest},
+		{
+			query: "namespace=team-a",
+			token: "alice",
+			code:  http.StatusOK,
+			want: `# HELP openshift_build_info Test.
+# TYPE openshift_build_info gauge
+openshift_build_info{namespace="team-a"} 1
+# HELP openshift_route_info Test.
+# TYPE openshift_route_info gauge
+openshift_route_info{namespace="team-a"} 1
+`,
+		},
+		{
+			query: "namespace=team-b,team-c&namespace=team-a",
+			token: "alice",
+			code:  http.StatusOK,
+			want: `# HELP openshift_build_info Test.
+# TYPE openshift_build_info gauge
+openshift_build_info{namespace="team-a"} 1
+# HELP openshift_route_info Test.
+# TYPE openshift_route_info gauge
+openshift_route_info{namespace="team-a"} 1
+openshift_route_info{namespace="team-b"} 1
+`,
+		},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/metrics/tenant?"+test.query, nil)
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%q with token %q: expected %d, got %d", test.query, test.token, test.code, w.Code)
+			continue
+		}
+		if test.want != "" && w.Body.String() != test.want {
+			t.Errorf("%q with token %q: expected body\n%s\ngot\n%s", test.query, test.token, test.want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package options
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultConfigReloadInterval is how often the config file is checked for
+// changes.
+const DefaultConfigReloadInterval = 10 * time.Second
+
+// reloadableFlags are the options which take effect when the config file is
+// reloaded. All other options need a restart.
+var reloadableFlags = map[string]struct{}{
+	"collectors":                   {},
+	"namespace":                    {},
+	"namespace-selector":           {},
+	"namespaces-denylist":          {},
+	"metric-whitelist":             {},
+	"metric-blacklist":             {},
+	"metric-labels-allowlist":      {},
+	"metric-annotations-allowlist": {},
+}
+
+var (
+	ConfigReloadsTotalMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "openshift_state_metrics_config_reloads_total",
+			Help: "Number of reloads of the config file by result.",
+		},
+		[]string{"result"},
+	)
+	ConfigLastReloadSuccessfulMetric = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "openshift_state_metrics_config_last_reload_successful",
+			Help: "Whether the last reload of the config file succeeded (1) or failed (0).",
+		},
+	)
+	ConfigLastReloadSuccessTimestampMetric = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "openshift_state_metrics_config_last_reload_success_timestamp_seconds",
+			Help: "Unix timestamp of the last successful load of the config file.",
+		},
+	)
+)
+
+// LoadConfig sets the options from the YAML config file at path. Its keys are
+// flag names without the leading dashes. Lists are joined with commas, maps
+// are written as key=value or, if the values are lists, as key=[a,b]. Options
+// given on the command line take precedence over the config file.
+func (o *Options) LoadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+
+	config := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	if err := decoder.Decode(&config); err != nil {
+		return fmt.Errorf("cannot parse %s: expected a map of options: %w", path, err)
+	}
+
+	changed := map[string]bool{}
+	o.flags.Visit(func(f *pflag.Flag) { changed[f.Name] = true })
+
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if o.flags.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("unknown option %q in %s", name, path)
+		}
+		if changed[name] {
+			continue
+		}
+		value, err := flagValue(config[name])
+		if err != nil {
+			return fmt.Errorf("invalid option %q in %s: %w", name, path, err)
+		}
+		if err := o.flags.Set(name, value); err != nil {
+			return fmt.Errorf("invalid option %q in %s: %w", name, path, err)
+		}
+	}
+
+	return nil
+}
+
+// flagValue converts a value of the config file to its command line form.
+func flagValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := scalarValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			if list, ok := v[k].([]interface{}); ok {
+				s, err := flagValue(list)
+				if err != nil {
+					return "", err
+				}
+				items[i] = fmt.Sprintf("%s=[%s]", k, s)
+				continue
+			}
+			s, err := scalarValue(v[k])
+			if err != nil {
+				return "", err
+			}
+			items[i] = k + "=" + s
+		}
+		return strings.Join(items, ","), nil
+	}
+	return scalarValue(v)
+}
+
+func scalarValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string, bool, json.Number:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("expected a string, number or boolean, got %v", v)
+}
+
+// Reload returns the options given on the command line with the config file
+// read again. The klog flags of the result do not change the global klog
+// settings, they only take effect after a restart.
+func (o *Options) Reload() (*Options, error) {
+	n := NewOptions()
+	n.detachKlogFlags = true
+	n.AddFlags()
+	if err := n.Parse(); err != nil {
+		return nil, err
+	}
+	if n.Config != "" {
+		if err := n.LoadConfig(n.Config); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// RestartRequired returns the names of the options which differ between o
+// and n, but only take effect after a restart.
+func (o *Options) RestartRequired(n *Options) []string {
+	var names []string
+	o.flags.VisitAll(func(f *pflag.Flag) {
+		if _, ok := reloadableFlags[f.Name]; ok {
+			return
+		}
+		if other := n.flags.Lookup(f.Name); other != nil && other.Value.String() != f.Value.String() {
+			names = append(names, f.Name)
+		}
+	})
+	return names
+}
+
+// WatchConfig calls reload whenever the config file at path changes, which is
+// checked every interval, and whenever a signal is received on hup, until ctx
+// is done. It returns right away, the file is watched in the background. The
+// results are reported in the config reload metrics.
+func WatchConfig(ctx context.Context, path string, interval time.Duration, hup <-chan os.Signal, reload func() error) {
+	ConfigLastReloadSuccessfulMetric.Set(1)
+	ConfigLastReloadSuccessTimestampMetric.SetToCurrentTime()
+
+	last, err := os.ReadFile(path)
+	if err != nil {
+		klog.Errorf("Failed to read config file %s: %v", path, err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				klog.Infof("Received SIGHUP, reloading config file %s", path)
+				if data, err := os.ReadFile(path); err == nil {
+					last = data
+				}
+			case <-ticker.C:
+				data, err := os.ReadFile(path)
+				if err != nil || bytes.Equal(data, last) {
+					continue
+				}
+				last = data
+				klog.Infof("Config file %s changed, reloading it", path)
+			}
+
+			if err := reload(); err != nil {
+				klog.Errorf("Failed to reload config file %s, keeping the previous configuration: %v", path, err)
+				ConfigReloadsTotalMetric.WithLabelValues("failure").Inc()
+				ConfigLastReloadSuccessfulMetric.Set(0)
+				continue
+			}
+			klog.Infof("Reloaded config file %s", path)
+			ConfigReloadsTotalMetric.WithLabelValues("success").Inc()
+			ConfigLastReloadSuccessfulMetric.Set(1)
+			ConfigLastReloadSuccessTimestampMetric.SetToCurrentTime()
+		}
+	}()
+}
@@ -0,0 +1,164 @@
+package options
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/klog/v2"
+	koptions "k8s.io/kube-state-metrics/pkg/options"
+)
+
+func newTestOptions(t *testing.T, args ...string) *Options {
+	t.Helper()
+	o := NewOptions()
+	o.AddFlags()
+	if err := o.flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func writeConfig(t *testing.T, path string, config string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, `
+collectors: [routes, builds]
+namespace:
+- ns1
+- ns2
+port: 8080
+metric-labels-allowlist:
+  routes: [team, app]
+  builds: ["*"]
+auth-resource-attributes:
+  resource: services
+  namespace: openshift-monitoring
+auth-cache-ttl: 30s
+enable-gzip-encoding: true
+`)
+
+	o := newTestOptions(t, "--port=9090")
+	if err := o.LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (CollectorSet{"routes": {}, "builds": {}}); !reflect.DeepEqual(o.Collectors, want) {
+		t.Errorf("expected collectors %v, got %v", want, o.Collectors)
+	}
+	if want := (koptions.NamespaceList{"ns1", "ns2"}); !reflect.DeepEqual(o.Namespaces, want) {
+		t.Errorf("expected namespaces %v, got %v", want, o.Namespaces)
+	}
+	if o.Port != 9090 {
+		t.Errorf("expected the port of the command line to take precedence, got %d", o.Port)
+	}
+	if want := (LabelsAllowList{"routes": {"team", "app"}, "builds": {"*"}}); !reflect.DeepEqual(o.MetricLabelsAllowlist, want) {
+		t.Errorf("expected labels allowlist %v, got %v", want, o.MetricLabelsAllowlist)
+	}
+	if want := map[string]string{"resource": "services", "namespace": "openshift-monitoring"}; !reflect.DeepEqual(o.AuthResourceAttributes, want) {
+		t.Errorf("expected resource attributes %v, got %v", want, o.AuthResourceAttributes)
+	}
+	if o.AuthCacheTTL != 30*time.Second || !o.EnableGZIPEncoding {
+		t.Errorf("expected auth cache TTL 30s and gzip encoding, got %s and %t", o.AuthCacheTTL, o.EnableGZIPEncoding)
+	}
+
+	for _, config := range []string{
+		"unknown-option: true",
+		"config: other.yaml",
+		"collectors: [unknown]",
+		"port: [80, 81]",
+		"- routes",
+	} {
+		writeConfig(t, path, config)
+		if err := newTestOptions(t).LoadConfig(path); err == nil {
+			t.Errorf("expected an error for config %q", config)
+		}
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	o := newTestOptions(t, "--port=8080", "--collectors=routes")
+	n := newTestOptions(t, "--port=9090", "--collectors=builds", "--namespace=ns1")
+
+	if got := o.RestartRequired(n); !reflect.DeepEqual(got, []string{"port"}) {
+		t.Errorf("expected only port to require a restart, got %v", got)
+	}
+}
+
+func TestReloadKeepsKlogSettings(t *testing.T) {
+	o := newTestOptions(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "v: 5\ncollectors: [routes]\n")
+	n := NewOptions()
+	n.detachKlogFlags = true
+	n.AddFlags()
+	if err := n.flags.Parse([]string{"--logtostderr=false"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if klog.V(5).Enabled() {
+		t.Error("expected the reloaded options not to change the verbosity of klog")
+	}
+	if got := o.flags.Lookup("logtostderr").Value.String(); got != "true" {
+		t.Errorf("expected the reloaded options not to change logtostderr, got %s", got)
+	}
+	if got := o.RestartRequired(n); !reflect.DeepEqual(got, []string{"logtostderr", "v"}) {
+		t.Errorf("expected the klog flags to require a restart, got %v", got)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "collectors: [routes]\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hup := make(chan os.Signal)
+	reloads := make(chan struct{})
+	WatchConfig(ctx, path, 10*time.Millisecond, hup, func() error {
+		reloads <- struct{}{}
+		return nil
+	})
+
+	expectReload := func(reason string) {
+		t.Helper()
+		select {
+		case <-reloads:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected a reload %s", reason)
+		}
+	}
+
+	writeConfig(t, path, "collectors: [builds]\n")
+	expectReload("when the file changes")
+	hup <- os.Interrupt
+	expectReload("on SIGHUP")
+
+	select {
+	case <-reloads:
+		t.Fatal("expected no reload while the file does not change")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	successes := testutil.ToFloat64(ConfigReloadsTotalMetric.WithLabelValues("success"))
+	if successes < 2 {
+		t.Errorf("expected at least 2 successful reloads, got %v", successes)
+	}
+	if got := testutil.ToFloat64(ConfigLastReloadSuccessfulMetric); got != 1 {
+		t.Errorf("expected the last reload to be successful, got %v", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/pflag"
 
@@ -12,27 +13,66 @@ import (
 )
 
 type Options struct {
-	Apiserver       string
-	Kubeconfig      string
-	Help            bool
-	Port            int
-	Host            string
-	TelemetryPort   int
-	TelemetryHost   string
-	Collectors      koptions.CollectorSet
-	Namespaces      koptions.NamespaceList
-	MetricBlacklist koptions.MetricSet
-	MetricWhitelist koptions.MetricSet
-	Version         bool
-
-	EnableGZIPEncoding bool
+	Apiserver                     string
+	Kubeconfig                    string
+	Config                        string
+	Help                          bool
+	Port                          int
+	Host                          string
+	TelemetryPort                 int
+	TelemetryHost                 string
+	Collectors                    CollectorSet
+	Namespaces                    koptions.NamespaceList
+	NamespaceSelector             string
+	NamespacesDenylist            koptions.NamespaceList
+	MetricBlacklist               koptions.MetricSet
+	MetricWhitelist               koptions.MetricSet
+	MetricLabelsAllowlist         LabelsAllowList
+	MetricAnnotationsAllowlist    LabelsAllowList
+	CustomResourceStateConfigFile string
+	Version                       bool
+	FromDir                       string
+
+	EnableGZIPEncoding   bool
+	Compression          []string
+	CompressionGzipLevel int
+	CompressionZstdLevel int
+	LivezMaxStaleness    time.Duration
+	ShutdownGracePeriod  time.Duration
+
+	TLSCertFile       string
+	TLSPrivateKeyFile string
+	ClientCAFile      string
+	TLSMinVersion     string
+	TLSCipherSuites   []string
+
+	AuthDelegation         bool
+	AuthNonResourceURL     string
+	AuthResourceAttributes map[string]string
+	AuthCacheTTL           time.Duration
+
+	EnableAdminAPI         bool
+	EnableDebugObjects     bool
+	WatchEventsByNamespace bool
+	MetadataOnlyWatches    bool
+	ListPageSize           int64
+	EnableWatchList        bool
+
+	Shard        int32
+	TotalShards  int
+	AutoSharding bool
+	Pod          string
+	PodNamespace string
 
 	flags *pflag.FlagSet
+	// detachKlogFlags keeps the klog flags from changing the global klog
+	// settings, see Reload.
+	detachKlogFlags bool
 }
 
 func NewOptions() *Options {
 	return &Options{
-		Collectors:      koptions.CollectorSet{},
+		Collectors:      CollectorSet{},
 		MetricWhitelist: koptions.MetricSet{},
 		MetricBlacklist: koptions.MetricSet{},
 	}
@@ -43,6 +83,9 @@ func (o *Options) AddFlags() {
 	// add klog flags
 	klogFlags := flag.NewFlagSet("klog", flag.ExitOnError)
 	klog.InitFlags(klogFlags)
+	if o.detachKlogFlags {
+		klogFlags = detachFlags(klogFlags)
+	}
 	o.flags.AddGoFlagSet(klogFlags)
 	o.flags.Lookup("logtostderr").Value.Set("true")
 	o.flags.Lookup("logtostderr").DefValue = "true"
@@ -55,20 +98,99 @@ func (o *Options) AddFlags() {
 
 	o.flags.StringVar(&o.Apiserver, "apiserver", "", `The URL of the apiserver to use as a master`)
 	o.flags.StringVar(&o.Kubeconfig, "kubeconfig", "", "Absolute path to the kubeconfig file")
+	o.flags.StringVar(&o.Config, "config", "", "Path to a YAML file setting options by flag name. Options given on the command line take precedence. The file is reloaded on SIGHUP and when it changes; collectors, namespaces and metric allow and deny lists are applied without a restart.")
 	o.flags.BoolVarP(&o.Help, "help", "h", false, "Print Help text")
 	o.flags.IntVar(&o.Port, "port", 80, `Port to expose metrics on.`)
 	o.flags.StringVar(&o.Host, "host", "0.0.0.0", `Host to expose metrics on.`)
 	o.flags.IntVar(&o.TelemetryPort, "telemetry-port", 81, `Port to expose openshift-state-metrics self metrics on.`)
 	o.flags.StringVar(&o.TelemetryHost, "telemetry-host", "0.0.0.0", `Host to expose openshift-state-metrics self metrics on.`)
-	o.flags.Var(&o.Collectors, "collectors", fmt.Sprintf("Comma-separated list of collectors to be enabled. Defaults to %q", &DefaultCollectors))
 	o.flags.Var(&o.Namespaces, "namespace", fmt.Sprintf("Comma-separated list of namespaces to be enabled. Defaults to %q", &DefaultNamespaces))
+	o.flags.StringVar(&o.NamespaceSelector, "namespace-selector", "", "Label selector on namespaces. Only namespaces matching it are enabled, namespaces entering or leaving the selection are picked up at runtime.")
+	o.flags.Var(&o.NamespacesDenylist, "namespaces-denylist", "Comma-separated list of namespaces not to be enabled.")
+	o.addMetricFlags()
+	o.flags.BoolVarP(&o.Version, "version", "", false, "openshift-state-metrics build version information")
+
+	o.flags.BoolVar(&o.EnableGZIPEncoding, "enable-gzip-encoding", false, "Gzip responses when requested by clients via 'Accept-Encoding: gzip' header. Same as adding gzip to --compression.")
+	o.flags.StringSliceVar(&o.Compression, "compression", nil, "Comma-separated list of encodings, gzip and zstd, used to compress responses when accepted by the client. Of the encodings the client accepts with the highest quality, the first one listed is used.")
+	o.flags.IntVar(&o.CompressionGzipLevel, "compression-gzip-level", 6, "Gzip compression level, from 1 (best speed) to 9 (best compression).")
+	o.flags.IntVar(&o.CompressionZstdLevel, "compression-zstd-level", 3, "Zstd compression level, from 1 (best speed) to 22 (best compression).")
+	o.flags.Int32Var(&o.Shard, "shard", 0, "The shard index of this instance, starting at 0. Only objects whose UID hashes to this shard are exposed.")
+	o.flags.IntVar(&o.TotalShards, "total-shards", 1, "The total number of shards. Sharding is disabled when set to 1.")
+	o.flags.BoolVar(&o.AutoSharding, "auto-sharding", false, "Determine the shard from the ordinal of the StatefulSet pod given by --pod and the total number of shards from the StatefulSet replicas. Collectors are rebuilt when the StatefulSet is scaled.")
+	o.flags.StringVar(&o.Pod, "pod", os.Getenv("POD_NAME"), "Name of the pod this instance runs in. Used by --auto-sharding.")
+	o.flags.StringVar(&o.PodNamespace, "pod-namespace", os.Getenv("POD_NAMESPACE"), "Namespace of the pod this instance runs in. Used by --auto-sharding.")
+	o.flags.StringVar(&o.TLSCertFile, "tls-cert-file", "", "File containing the certificate to serve the metrics and telemetry ports with TLS. Reloaded when it changes on disk.")
+	o.flags.StringVar(&o.TLSPrivateKeyFile, "tls-private-key-file", "", "File containing the private key matching --tls-cert-file. Reloaded when it changes on disk.")
+	o.flags.StringVar(&o.ClientCAFile, "client-ca-file", "", "File containing the CA bundle client certificates are verified with. If set, clients must present a certificate signed by it. Reloaded when it changes on disk.")
+	o.flags.StringVar(&o.TLSMinVersion, "tls-min-version", "VersionTLS12", "Minimum TLS version supported. One of VersionTLS10, VersionTLS11, VersionTLS12 and VersionTLS13.")
+	o.flags.StringSliceVar(&o.TLSCipherSuites, "tls-cipher-suites", nil, "Comma-separated list of cipher suites for TLS 1.2 and below. The Go defaults are used if not set.")
+	o.flags.BoolVar(&o.AuthDelegation, "auth-delegation", false, "Authenticate requests to the metrics and telemetry ports with client certificates or bearer tokens checked by TokenReviews, and authorize them with SubjectAccessReviews. Health endpoints are not protected.")
+	o.flags.StringVar(&o.AuthNonResourceURL, "auth-non-resource-url", "", "Non-resource URL requests are authorized for with --auth-delegation. Defaults to the path of the request.")
+	o.flags.StringToStringVar(&o.AuthResourceAttributes, "auth-resource-attributes", nil, "Resource attributes requests are authorized for with --auth-delegation instead of a non-resource URL, e.g. namespace=openshift-monitoring,resource=services,subresource=metrics,name=openshift-state-metrics. Supported keys are namespace, apiGroup, apiVersion, resource, subresource and name.")
+	o.flags.DurationVar(&o.AuthCacheTTL, "auth-cache-ttl", time.Minute, "How long authentication and authorization decisions are cached with --auth-delegation. 0 disables caching.")
+	o.flags.BoolVar(&o.EnableAdminAPI, "enable-admin-api", false, "Serve a JSON admin API on the telemetry port which lists the collectors and enables or disables them at runtime. Requires --auth-delegation.")
+	o.flags.BoolVar(&o.EnableDebugObjects, "enable-debug-objects", false, "Serve the metrics generated for single objects as JSON on /debug/objects on the telemetry port. Protect it with --auth-delegation.")
+	o.flags.Int64Var(&o.ListPageSize, "list-page-size", 0, "Number of objects per page of the lists of the collectors. Paginated lists are read from etcd instead of the watch cache of the apiserver, but keep the memory of both bounded for large resources. 0 lists all objects at once.")
+	o.flags.BoolVar(&o.EnableWatchList, "enable-watch-list", false, "Stream the initial objects of the collectors through watches instead of listing them, if the apiserver supports the WatchList feature. Falls back to lists otherwise.")
+	o.flags.BoolVar(&o.MetadataOnlyWatches, "metadata-only-watches", false, "Watch only the metadata of the objects of collectors whose enabled metrics need nothing else, e.g. because the others are denied by --metric-blacklist. Saves memory and apiserver bandwidth.")
+	o.flags.BoolVar(&o.WatchEventsByNamespace, "watch-events-by-namespace", false, "Also count the watch events of every collector per namespace. Adds a self metric series per namespace, collector and event type.")
+	o.flags.DurationVar(&o.ShutdownGracePeriod, "shutdown-grace-period", 20*time.Second, "Time in-flight requests get to complete on SIGTERM before the servers are closed. Should be shorter than the termination grace period of the pod.")
+	o.flags.DurationVar(&o.LivezMaxStaleness, "livez-max-staleness", 15*time.Minute, "Maximum time a reflector may go without a successful list or watch before /livez fails. 0 disables the check.")
+}
+
+// AddRenderFlags adds the flags of the render subcommand instead of the ones
+// of the server.
+func (o *Options) AddRenderFlags() {
+	o.flags = pflag.NewFlagSet("render", pflag.ExitOnError)
+	klogFlags := flag.NewFlagSet("klog", flag.ExitOnError)
+	klog.InitFlags(klogFlags)
+	o.flags.AddGoFlagSet(klogFlags)
+
+	o.flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s render:\n", os.Args[0])
+		o.flags.PrintDefaults()
+	}
+
+	o.flags.StringVar(&o.FromDir, "from-dir", "", "Directory with the YAML and JSON manifests to render, e.g. a must-gather dump. It is searched recursively.")
+	o.flags.BoolVarP(&o.Help, "help", "h", false, "Print Help text")
+	o.addMetricFlags()
+}
+
+// addMetricFlags adds the flags selecting the generated metrics, which the
+// server and the render subcommand share.
+func (o *Options) addMetricFlags() {
+	o.flags.Var(&o.Collectors, "collectors", fmt.Sprintf("Comma-separated list of collectors to be enabled. Defaults to %q", &DefaultCollectors))
 	o.flags.Var(&o.MetricWhitelist, "metric-whitelist", "Comma-separated list of metrics to be exposed. The whitelist and blacklist are mutually exclusive.")
 	o.flags.Var(&o.MetricBlacklist, "metric-blacklist", "Comma-separated list of metrics not to be enabled. The whitelist and blacklist are mutually exclusive.")
-	o.flags.BoolVarP(&o.Version, "version", "", false, "openshift-state-metrics build version information")
+	o.flags.Var(&o.MetricLabelsAllowlist, "metric-labels-allowlist", "Kubernetes labels exposed by the labels metric of each collector, e.g. builds=[team,app],routes=[*]. Once set, collectors not listed expose no labels. By default all labels are exposed.")
+	o.flags.Var(&o.MetricAnnotationsAllowlist, "metric-annotations-allowlist", "Kubernetes annotations exposed by the annotations metric of each collector, e.g. builds=[owner],routes=[*]. By default no annotations are exposed.")
+	o.flags.StringVar(&o.CustomResourceStateConfigFile, "custom-resource-state-config-file", "", "Path to a YAML file describing metrics for custom resources. A collector is enabled for every resource in the file.")
+}
 
-	o.flags.BoolVar(&o.EnableGZIPEncoding, "enable-gzip-encoding", false, "Gzip responses when requested by clients via 'Accept-Encoding: gzip' header.")
+// detachFlags returns a copy of the given flags which holds their values
+// itself instead of setting the variables of the flags.
+func detachFlags(flags *flag.FlagSet) *flag.FlagSet {
+	detached := flag.NewFlagSet(flags.Name(), flag.ContinueOnError)
+	flags.VisitAll(func(f *flag.Flag) {
+		v := &detachedValue{value: f.Value.String()}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok {
+			v.isBool = b.IsBoolFlag()
+		}
+		detached.Var(v, f.Name, f.Usage)
+	})
+	return detached
 }
 
+// detachedValue is a flag.Value which only holds its value.
+type detachedValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *detachedValue) String() string     { return v.value }
+func (v *detachedValue) Set(s string) error { v.value = s; return nil }
+func (v *detachedValue) IsBoolFlag() bool   { return v.isBool }
+
 func (o *Options) Parse() error {
 	err := o.flags.Parse(os.Args)
 	return err
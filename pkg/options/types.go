@@ -0,0 +1,103 @@
+package options
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/openshift/openshift-state-metrics/pkg/collectors"
+)
+
+// CollectorSet is a set of collectors. Only the built-in collectors and the
+// ones added with collectors.RegisterCollector can be set.
+type CollectorSet map[string]struct{}
+
+func (c *CollectorSet) String() string {
+	return strings.Join(c.AsSlice(), ",")
+}
+
+func (c *CollectorSet) Set(value string) error {
+	s := *c
+	available := collectors.AvailableCollectors()
+	for _, col := range strings.Split(value, ",") {
+		col = strings.TrimSpace(col)
+		if col == "" {
+			continue
+		}
+		if !slices.Contains(available, col) {
+			return fmt.Errorf("collector %q does not exist", col)
+		}
+		s[col] = struct{}{}
+	}
+	return nil
+}
+
+func (c *CollectorSet) Type() string {
+	return "string"
+}
+
+// AsSlice returns the sorted names of the collectors.
+func (c CollectorSet) AsSlice() []string {
+	cols := make([]string, 0, len(c))
+	for col := range c {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// LabelsAllowList maps collectors to the Kubernetes labels or annotations
+// allowed in their metrics. On the command line it is given as e.g.
+// "builds=[team,app],routes=[*]".
+type LabelsAllowList map[string][]string
+
+func (l *LabelsAllowList) String() string {
+	collectors := make([]string, 0, len(*l))
+	for c := range *l {
+		collectors = append(collectors, c)
+	}
+	sort.Strings(collectors)
+
+	s := make([]string, len(collectors))
+	for i, c := range collectors {
+		s[i] = fmt.Sprintf("%s=[%s]", c, strings.Join((*l)[c], ","))
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *LabelsAllowList) Set(value string) error {
+	m := LabelsAllowList{}
+	value = strings.TrimSpace(value)
+	for value != "" {
+		i := strings.Index(value, "=[")
+		j := strings.Index(value, "]")
+		if i <= 0 || j < i {
+			return fmt.Errorf("invalid allowlist %q, expected e.g. builds=[team,app],routes=[*]", value)
+		}
+
+		collector := strings.TrimSpace(value[:i])
+		names := []string{}
+		for _, name := range strings.Split(value[i+2:j], ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		m[collector] = append(m[collector], names...)
+
+		value = strings.TrimSpace(value[j+1:])
+		if value != "" {
+			if !strings.HasPrefix(value, ",") {
+				return fmt.Errorf("invalid allowlist, expected a comma before %q", value)
+			}
+			value = strings.TrimSpace(value[1:])
+		}
+	}
+
+	*l = m
+	return nil
+}
+
+func (l *LabelsAllowList) Type() string {
+	return "string"
+}
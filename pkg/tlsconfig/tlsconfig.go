@@ -0,0 +1,227 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// DefaultReloadInterval is how often the certificate files are checked for
+// changes.
+const DefaultReloadInterval = 10 * time.Second
+
+var versions = map[string]uint16{
+	"VersionTLS10": tls.VersionTLS10,
+	"VersionTLS11": tls.VersionTLS11,
+	"VersionTLS12": tls.VersionTLS12,
+	"VersionTLS13": tls.VersionTLS13,
+}
+
+// Config configures TLS on the metrics and telemetry listeners.
+type Config struct {
+	// CertFile and KeyFile hold the PEM encoded serving certificate and its
+	// private key. TLS is disabled if both are empty.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile holds the PEM encoded CA bundle client certificates are
+	// verified with. Clients must present a certificate if it is set,
+	// unless OptionalClientCert is set.
+	ClientCAFile string
+	// OptionalClientCert allows clients to connect without a certificate,
+	// e.g. because they authenticate with a bearer token instead.
+	OptionalClientCert bool
+	// MinVersion is the minimum TLS version, e.g. "VersionTLS12".
+	MinVersion string
+	// CipherSuites are the names of the cipher suites used up to TLS 1.2,
+	// e.g. "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256". The Go defaults are used
+	// if it is empty.
+	CipherSuites []string
+}
+
+// Enabled returns whether the listeners serve TLS.
+func (c Config) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// Validate checks that the configuration is complete and refers to known TLS
+// versions and cipher suites. It does not read the files.
+func (c Config) Validate() error {
+	if c.CertFile == "" && c.KeyFile != "" || c.CertFile != "" && c.KeyFile == "" {
+		return fmt.Errorf("both the certificate and the private key must be set")
+	}
+	if c.ClientCAFile != "" && !c.Enabled() {
+		return fmt.Errorf("a client CA requires a certificate and a private key")
+	}
+	if _, err := minVersion(c.MinVersion); err != nil {
+		return err
+	}
+	_, err := cipherSuites(c.CipherSuites)
+	return err
+}
+
+// NewServerConfig returns a TLS configuration for servers. The certificate,
+// the private key and the client CA are loaded once and then reloaded every
+// interval until the context is done, so that rotated certificates are picked
+// up without a restart. A reload failing leaves the previous files in use.
+func NewServerConfig(ctx context.Context, c Config, interval time.Duration) (*tls.Config, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	version, _ := minVersion(c.MinVersion)
+	suites, _ := cipherSuites(c.CipherSuites)
+
+	r := &reloader{config: c}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	go r.run(ctx, interval)
+
+	base := &tls.Config{
+		MinVersion:   version,
+		CipherSuites: suites,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	config := base.Clone()
+	config.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, _ := r.get()
+		return cert, nil
+	}
+	// The client CA can change, the configuration is built per connection.
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cert, clientCAs := r.get()
+		conf := base.Clone()
+		conf.Certificates = []tls.Certificate{*cert}
+		if clientCAs != nil {
+			conf.ClientAuth = tls.RequireAndVerifyClientCert
+			if c.OptionalClientCert {
+				conf.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+			conf.ClientCAs = clientCAs
+		}
+		return conf, nil
+	}
+	return config, nil
+}
+
+// reloader keeps the certificate and client CA in sync with the files.
+type reloader struct {
+	config Config
+
+	mu        sync.RWMutex
+	certPEM   []byte
+	keyPEM    []byte
+	caPEM     []byte
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+func (r *reloader) get() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.clientCAs
+}
+
+func (r *reloader) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.reload(); err != nil {
+				klog.Errorf("Failed to reload TLS certificates, keeping the previous ones: %v", err)
+			}
+		}
+	}
+}
+
+// reload reads the files and replaces the certificate and client CA if any
+// of them changed.
+func (r *reloader) reload() error {
+	certPEM, err := os.ReadFile(r.config.CertFile)
+	if err != nil {
+		return err
+	}
+	keyPEM, err := os.ReadFile(r.config.KeyFile)
+	if err != nil {
+		return err
+	}
+	var caPEM []byte
+	if r.config.ClientCAFile != "" {
+		if caPEM, err = os.ReadFile(r.config.ClientCAFile); err != nil {
+			return err
+		}
+	}
+
+	r.mu.RLock()
+	unchanged := bytes.Equal(certPEM, r.certPEM) && bytes.Equal(keyPEM, r.keyPEM) && bytes.Equal(caPEM, r.caPEM)
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to load %s and %s: %v", r.config.CertFile, r.config.KeyFile, err)
+	}
+	var clientCAs *x509.CertPool
+	if caPEM != nil {
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no certificates found in %s", r.config.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert != nil {
+		klog.Info("Reloaded TLS certificates")
+	}
+	r.certPEM, r.keyPEM, r.caPEM = certPEM, keyPEM, caPEM
+	r.cert, r.clientCAs = &cert, clientCAs
+	return nil
+}
+
+func minVersion(name string) (uint16, error) {
+	if name == "" {
+		return tls.VersionTLS12, nil
+	}
+	v, ok := versions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q, supported are VersionTLS10, VersionTLS11, VersionTLS12 and VersionTLS13", name)
+	}
+	return v, nil
+}
+
+func cipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := map[string]uint16{}
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[s.Name] = s.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	var unknown []string
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown cipher suites: %s", strings.Join(unknown, ", "))
+	}
+	return ids, nil
+}
@@ -0,0 +1,313 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert returns a certificate signed by parent, or a self-signed CA if
+// parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serve starts an HTTPS server with the given configuration and returns its
+// URL.
+func serve(t *testing.T, config *tls.Config) string {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.TLS = config
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func get(url string, roots *x509.CertPool, clientCert *tls.Certificate) (*x509.Certificate, error) {
+	config := &tls.Config{RootCAs: roots, ServerName: "osm.example.com"}
+	if clientCert != nil {
+		// Sent even if it is not signed by a CA the server asks for.
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return clientCert, nil
+		}
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	defer client.CloseIdleConnections()
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return resp.TLS.PeerCertificates[0], nil
+}
+
+func TestServerConfigReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	c := Config{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key")}
+	first := newTestCert(t, "osm.example.com", ca)
+	writeFile(t, c.CertFile, first.certPEM)
+	writeFile(t, c.KeyFile, first.keyPEM)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config, err := NewServerConfig(ctx, c, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serve(t, config)
+
+	got, err := get(url, roots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(first.cert) {
+		t.Fatalf("expected the first certificate to be served")
+	}
+
+	// A certificate not matching the key is ignored.
+	second := newTestCert(t, "osm.example.com", ca)
+	writeFile(t, c.CertFile, second.certPEM)
+	time.Sleep(50 * time.Millisecond)
+	if got, err = get(url, roots, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(first.cert) {
+		t.Fatalf("expected the first certificate to be kept while the key does not match")
+	}
+
+	writeFile(t, c.KeyFile, second.keyPEM)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if got, err = get(url, roots, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got.Equal(second.cert) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the rotated certificate to be served")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerConfigClientCA(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	otherCA := newTestCert(t, "other-ca", nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	c := Config{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "client-ca.crt"),
+	}
+	serving := newTestCert(t, "osm.example.com", ca)
+	writeFile(t, c.CertFile, serving.certPEM)
+	writeFile(t, c.KeyFile, serving.keyPEM)
+	writeFile(t, c.ClientCAFile, ca.certPEM)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config, err := NewServerConfig(ctx, c, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serve(t, config)
+
+	client := newTestCert(t, "prometheus", ca).tlsCertificate(t)
+	untrusted := newTestCert(t, "prometheus", otherCA).tlsCertificate(t)
+
+	if _, err := get(url, roots, &client); err != nil {
+		t.Errorf("expected a client with a trusted certificate to be accepted: %v", err)
+	}
+	if _, err := get(url, roots, nil); err == nil {
+		t.Errorf("expected a client without certificate to be rejected")
+	}
+	if _, err := get(url, roots, &untrusted); err == nil {
+		t.Errorf("expected a client with an untrusted certificate to be rejected")
+	}
+
+	optional := c
+	optional.OptionalClientCert = true
+	config, err = NewServerConfig(ctx, optional, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	optionalURL := serve(t, config)
+	if _, err := get(optionalURL, roots, nil); err != nil {
+		t.Errorf("expected a client without certificate to be accepted if certificates are optional: %v", err)
+	}
+	if _, err := get(optionalURL, roots, &untrusted); err == nil {
+		t.Errorf("expected a client with an untrusted certificate to be rejected if certificates are optional")
+	}
+
+	// Trusting the other CA instead is picked up without a restart.
+	writeFile(t, c.ClientCAFile, otherCA.certPEM)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := get(url, roots, &untrusted); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the reloaded client CA to be used")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerConfigMinVersionAndCipherSuites(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	c := Config{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		MinVersion:   "VersionTLS12",
+		CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+	}
+	serving := newTestCert(t, "osm.example.com", ca)
+	writeFile(t, c.CertFile, serving.certPEM)
+	writeFile(t, c.KeyFile, serving.keyPEM)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config, err := NewServerConfig(ctx, c, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serve(t, config)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		ServerName:   "osm.example.com",
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	}}}
+	if resp, err := client.Get(url); err == nil {
+		resp.Body.Close()
+		t.Errorf("expected a cipher suite not configured to be rejected")
+	}
+
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:    roots,
+		ServerName: "osm.example.com",
+		MaxVersion: tls.VersionTLS11,
+	}}}
+	if resp, err := client.Get(url); err == nil {
+		resp.Body.Close()
+		t.Errorf("expected TLS 1.1 to be rejected")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		config Config
+		valid  bool
+	}{
+		{Config{}, true},
+		{Config{CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: "ca.crt", MinVersion: "VersionTLS13"}, true},
+		{Config{CertFile: "tls.crt", KeyFile: "tls.key", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}}, true},
+		{Config{CertFile: "tls.crt"}, false},
+		{Config{KeyFile: "tls.key"}, false},
+		{Config{ClientCAFile: "ca.crt"}, false},
+		{Config{CertFile: "tls.crt", KeyFile: "tls.key", MinVersion: "TLS12"}, false},
+		{Config{CertFile: "tls.crt", KeyFile: "tls.key", CipherSuites: []string{"TLS_UNKNOWN"}}, false},
+	}
+
+	for i, test := range tests {
+		err := test.config.Validate()
+		if test.valid && err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+}